@@ -12,15 +12,20 @@ import {
 } from 'lucide-react';
 import { 
     loginAdmin, 
+    loginAdminTwoFactor,
+    logoutAdmin,
     getAdminStats, 
     getAdminUsers, 
     updateUserStatus, 
     addUserCredits,
     getAdminTransactions,
+    getAdminSettings,
+    updateAdminSettings,
 } from './api';
 import type { 
     AdminUser, 
     AdminStats,
+    AdminSettings,
     Transaction,
 } from './api';
 
@@ -30,12 +35,15 @@ function App() {
     const [stats, setStats] = useState<AdminStats | null>(null);
     const [users, setUsers] = useState<AdminUser[]>([]);
     const [transactions, setTransactions] = useState<Transaction[]>([]);
+    const [settings, setSettings] = useState<AdminSettings | null>(null);
     const [activeTab, setActiveTab] = useState<'dashboard' | 'users' | 'transactions'>('dashboard');
     const [searchTerm, setSearchTerm] = useState('');
     
     // Login Form State
     const [email, setEmail] = useState('');
     const [password, setPassword] = useState('');
+    const [mfaToken, setMfaToken] = useState<string | null>(null);
+    const [code, setCode] = useState('');
     const [error, setError] = useState('');
 
     useEffect(() => {
@@ -47,19 +55,24 @@ function App() {
     const fetchData = async () => {
         setLoading(true);
         try {
-            const [statsData, usersData, transactionsData] = await Promise.all([
+            const [statsData, usersData, transactionsData, settingsData] = await Promise.all([
                 getAdminStats(),
                 getAdminUsers(),
-                getAdminTransactions()
+                getAdminTransactions(),
+                getAdminSettings()
             ]);
             setStats(statsData);
             setUsers(usersData);
             setTransactions(transactionsData);
+            setSettings(settingsData);
         // eslint-disable-next-line @typescript-eslint/no-explicit-any
         } catch (err: any) {
             console.error(err);
             if (err.response?.status === 401) {
                 logout();
+            } else if (err.response?.status === 403) {
+                logout();
+                setError(err.response.data?.error || 'Admin access required');
             }
         } finally {
             setLoading(false);
@@ -71,19 +84,27 @@ function App() {
         setLoading(true);
         setError('');
         try {
-            const data = await loginAdmin(email, password);
+            const data = mfaToken
+                ? await loginAdminTwoFactor(mfaToken, code)
+                : await loginAdmin(email, password);
+            if (data.mfa_required) {
+                setMfaToken(data.mfa_token);
+                return;
+            }
             localStorage.setItem('admin_token', data.token);
             setToken(data.token);
+            setMfaToken(null);
+            setCode('');
         // eslint-disable-next-line @typescript-eslint/no-explicit-any
         } catch (err: any) {
-            setError(err.message || 'Login failed');
+            setError(err.response?.data?.error || err.message || 'Login failed');
         } finally {
             setLoading(false);
         }
     };
 
     const logout = () => {
-        localStorage.removeItem('admin_token');
+        logoutAdmin();
         setToken(null);
         setStats(null);
         setUsers([]);
@@ -127,6 +148,15 @@ function App() {
         }
     };
 
+    const handleRequireTwoFactor = async (required: boolean) => {
+        try {
+            setSettings(await updateAdminSettings({ admin_require_2fa: required }));
+        // eslint-disable-next-line @typescript-eslint/no-explicit-any
+        } catch (err: any) {
+            alert(err.response?.data?.error || 'Failed to update settings');
+        }
+    };
+
     const filteredUsers = users.filter(user => 
         user.name.toLowerCase().includes(searchTerm.toLowerCase()) || 
         user.email.toLowerCase().includes(searchTerm.toLowerCase())
@@ -138,6 +168,23 @@ function App() {
                 <div className="bg-white p-8 rounded-xl shadow-lg w-full max-w-md">
                     <h2 className="text-2xl font-bold text-center text-gray-800 mb-6">Admin Login</h2>
                     <form onSubmit={handleLogin}>
+                        {mfaToken ? (
+                        <div className="mb-6">
+                            <label className="block text-gray-700 text-sm font-bold mb-2">Authentication code</label>
+                            <input 
+                                type="text" 
+                                inputMode="numeric"
+                                autoComplete="one-time-code"
+                                placeholder="6-digit code or recovery code"
+                                value={code}
+                                onChange={(e) => setCode(e.target.value)}
+                                className="w-full px-3 py-2 border rounded-lg focus:outline-none focus:ring-2 focus:ring-blue-500" 
+                                required 
+                                autoFocus
+                            />
+                        </div>
+                        ) : (
+                        <>
                         <div className="mb-4">
                             <label className="block text-gray-700 text-sm font-bold mb-2">Email</label>
                             <input 
@@ -158,14 +205,25 @@ function App() {
                                 required 
                             />
                         </div>
+                        </>
+                        )}
                         <button 
                             type="submit" 
                             disabled={loading}
                             className="w-full bg-blue-600 text-white py-2 rounded-lg hover:bg-blue-700 transition disabled:opacity-50"
                         >
-                            {loading ? 'Logging in...' : 'Login'}
+                            {loading ? 'Logging in...' : mfaToken ? 'Verify' : 'Login'}
                         </button>
                     </form>
+                    {mfaToken && (
+                        <button
+                            type="button"
+                            onClick={() => { setMfaToken(null); setCode(''); setError(''); }}
+                            className="w-full mt-3 text-sm text-gray-500 hover:text-gray-700"
+                        >
+                            Start over
+                        </button>
+                    )}
                     {error && <p className="text-red-500 text-center mt-4 text-sm">{error}</p>}
                 </div>
             </div>
@@ -231,6 +289,7 @@ function App() {
                 ) : (
                     <>
                         {activeTab === 'dashboard' && (
+                            <>
                             <div className="grid grid-cols-1 md:grid-cols-3 gap-6 mb-8">
                                 <div className="bg-white p-6 rounded-xl shadow-sm border border-gray-200">
                                     <div className="flex justify-between items-start">
@@ -266,6 +325,21 @@ function App() {
                                     </div>
                                 </div>
                             </div>
+                            <div className="bg-white p-6 rounded-xl shadow-sm border border-gray-200">
+                                <label className="flex items-center justify-between gap-4">
+                                    <div>
+                                        <p className="font-semibold text-gray-900">Require two-factor authentication for admins</p>
+                                        <p className="text-gray-500 text-sm">Admins without it are kept out of this panel until they turn it on from their profile.</p>
+                                    </div>
+                                    <input
+                                        type="checkbox"
+                                        checked={settings?.admin_require_2fa || false}
+                                        onChange={(e) => handleRequireTwoFactor(e.target.checked)}
+                                        className="w-5 h-5"
+                                    />
+                                </label>
+                            </div>
+                            </>
                         )}
 
                         {activeTab === 'users' && (
@@ -12,6 +12,41 @@ api.interceptors.request.use((config) => {
     return config;
 });
 
+// Access tokens are short-lived: on a 401, trade the refresh token for a
+// new pair once and retry. Concurrent 401s share the same refresh.
+let refreshing: Promise<string | null> | null = null;
+
+const refreshSession = async () => {
+    const refreshToken = localStorage.getItem('admin_refresh_token');
+    if (!refreshToken) {
+        return null;
+    }
+    try {
+        const response = await axios.post(`${api.defaults.baseURL}/auth/refresh`, { refresh_token: refreshToken });
+        localStorage.setItem('admin_token', response.data.token);
+        localStorage.setItem('admin_refresh_token', response.data.refresh_token);
+        return response.data.token as string;
+    } catch {
+        return null;
+    }
+};
+
+api.interceptors.response.use(undefined, async (error) => {
+    const original = error.config;
+    if (error.response?.status !== 401 || !original || original._retried) {
+        return Promise.reject(error);
+    }
+    original._retried = true;
+
+    refreshing = refreshing || refreshSession().finally(() => { refreshing = null; });
+    const token = await refreshing;
+    if (!token) {
+        return Promise.reject(error);
+    }
+    original.headers.Authorization = `Bearer ${token}`;
+    return api(original);
+});
+
 export interface AdminUser {
     id: number;
     name: string;
@@ -39,12 +74,42 @@ export interface AdminStats {
     active_subscriptions: number;
 }
 
+export interface AdminSettings {
+    admin_require_2fa: boolean;
+}
+
+// eslint-disable-next-line @typescript-eslint/no-explicit-any
+const finishAdminLogin = (data: any) => {
+    if (data.user.role !== 'admin') {
+        throw new Error('Unauthorized: Admin access required');
+    }
+    localStorage.setItem('admin_refresh_token', data.refresh_token);
+    return data;
+};
+
+// With two-factor authentication on, the password step returns
+// mfa_required and an mfa_token to pass to loginAdminTwoFactor.
 export const loginAdmin = async (email: string, password: string) => {
     const response = await api.post('/auth/login', { email, password });
-    if (response.data.user.role !== 'admin') {
-        throw new Error('Unauthorized: Admin access required');
+    if (response.data.mfa_required) {
+        return response.data;
     }
-    return response.data;
+    return finishAdminLogin(response.data);
+};
+
+export const loginAdminTwoFactor = async (mfaToken: string, code: string) => {
+    const response = await api.post('/auth/login/2fa', { mfa_token: mfaToken, code });
+    return finishAdminLogin(response.data);
+};
+
+export const logoutAdmin = async () => {
+    try {
+        await api.post('/auth/logout');
+    } catch {
+        // The session is dropped locally either way
+    }
+    localStorage.removeItem('admin_token');
+    localStorage.removeItem('admin_refresh_token');
 };
 
 export const getAdminStats = async () => {
@@ -52,6 +117,16 @@ export const getAdminStats = async () => {
     return response.data;
 };
 
+export const getAdminSettings = async () => {
+    const response = await api.get<AdminSettings>('/admin/settings');
+    return response.data;
+};
+
+export const updateAdminSettings = async (data: Partial<AdminSettings>) => {
+    const response = await api.put<AdminSettings>('/admin/settings', data);
+    return response.data;
+};
+
 export const getAdminUsers = async () => {
     const response = await api.get<AdminUser[]>('/admin/users');
     return response.data;
@@ -20,12 +20,24 @@ func init() {
 	// We rely on the global DB variable in config.
 	config.ConnectDB()
 
+	// File storage: the local filesystem doesn't outlive an invocation, so
+	// set STORAGE_BACKEND=s3 in serverless deployments.
+	config.ConnectStorage()
+	config.ConnectMailer()
+
 	// Run Migrations (Safe for small apps, ensures DB is ready)
 	if err := models.Migrate(config.DB); err != nil {
 		// Log error but don't panic, let the app try to run
 		println("Migration failed:", err.Error())
 	}
 
+	// Background jobs (see backend/jobs) are not started here: serverless
+	// instances don't live long enough to run them. Recurring tasks still
+	// advance when an occurrence is completed through the API; the trash is
+	// only purged by a long-running instance or by deleting tasks
+	// permanently. Expired idempotency keys and tokens are never accepted
+	// again, so skipping their cleanup only costs storage.
+
 	// Setup Router
 	app = routes.SetupRouter()
 }
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"log"
+	"os"
+	"taskmanager-backend/backend/config"
+	"taskmanager-backend/backend/ledger"
+
+	"github.com/joho/godotenv"
+)
+
+// reconcile_credits checks that every user's credit balance equals the sum
+// of their transactions. It lists the users that don't match and exits
+// with status 1 if there are any.
+func main() {
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, relying on system env")
+	}
+
+	config.ConnectDB()
+
+	mismatches, err := ledger.Reconcile(config.DB)
+	if err != nil {
+		log.Fatalf("Failed to reconcile credits: %v", err)
+	}
+
+	for _, m := range mismatches {
+		log.Printf("User %d (%s): balance %d, transactions sum to %d (off by %d)", m.UserID, m.Email, m.Credits, m.Ledger, m.Credits-m.Ledger)
+	}
+	if len(mismatches) > 0 {
+		log.Printf("%d users do not reconcile", len(mismatches))
+		os.Exit(1)
+	}
+
+	log.Println("All balances match the transaction ledger")
+}
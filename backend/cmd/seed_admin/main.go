@@ -46,6 +46,12 @@ func main() {
 		log.Fatalf("Failed to create admin user: %v", err)
 	}
 
+	// Record the starting balance so the ledger reconciles
+	bonus := models.Transaction{UserID: admin.ID, Amount: admin.Credits, Type: "bonus", Description: "Initial sign-up credits"}
+	if err := config.DB.Create(&bonus).Error; err != nil {
+		log.Fatalf("Failed to record admin credits: %v", err)
+	}
+
 	log.Println("Admin user created successfully")
 	log.Printf("Email: %s", adminEmail)
 	log.Printf("Password: %s", password)
@@ -0,0 +1,29 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strings"
+	"taskmanager-backend/backend/mailer"
+)
+
+// Mailer sends transactional email, such as verification links.
+var Mailer mailer.Mailer
+
+func ConnectMailer() {
+	m, err := mailer.FromEnv()
+	if err != nil {
+		log.Fatalf("Failed to set up mailer: %v", err)
+	}
+
+	Mailer = m
+	log.Println("Mailer ready")
+}
+
+// AppURL is the frontend's base URL, used to build links in email.
+func AppURL() string {
+	if url := os.Getenv("APP_URL"); url != "" {
+		return strings.TrimRight(url, "/")
+	}
+	return "http://localhost:3000"
+}
@@ -0,0 +1,19 @@
+package config
+
+import (
+	"log"
+	"taskmanager-backend/backend/storage"
+)
+
+// Storage holds uploaded files, such as task attachments.
+var Storage storage.Backend
+
+func ConnectStorage() {
+	backend, err := storage.FromEnv()
+	if err != nil {
+		log.Fatalf("Failed to set up file storage: %v", err)
+	}
+
+	Storage = backend
+	log.Println("File storage ready")
+}
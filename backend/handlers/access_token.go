@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"net/http"
+	"taskmanager-backend/backend/config"
+	"taskmanager-backend/backend/models"
+	"taskmanager-backend/backend/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAccessTokens lists the current user's personal access tokens.
+func GetAccessTokens(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var tokens []models.PersonalAccessToken
+	if err := config.DB.Where("user_id = ?", userID).Order("created_at desc").Find(&tokens).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch API tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": tokens})
+}
+
+type CreateAccessTokenInput struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	Scopes        []string `json:"scopes" binding:"required,min=1,dive,oneof=read:tasks write:tasks billing"`
+	ExpiresInDays int      `json:"expires_in_days" binding:"omitempty,min=1,max=365"` // Zero never expires
+}
+
+// CreateAccessToken issues a personal access token. The token itself is
+// only in this response; it can't be shown again.
+func CreateAccessToken(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input CreateAccessTokenInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	secret, err := utils.NewOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create API token"})
+		return
+	}
+	raw := models.PersonalAccessTokenPrefix + secret
+
+	token := models.PersonalAccessToken{
+		UserID:    userID.(uint),
+		Name:      input.Name,
+		TokenHash: utils.HashOpaqueToken(raw),
+		Hint:      raw[len(raw)-4:],
+		Scopes:    uniqueScopes(input.Scopes),
+	}
+	if input.ExpiresInDays > 0 {
+		expires := time.Now().AddDate(0, 0, input.ExpiresInDays)
+		token.ExpiresAt = &expires
+	}
+	if err := config.DB.Create(&token).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create API token"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"token": raw, "data": token})
+}
+
+// uniqueScopes drops repeated scopes, keeping models.AccessTokenScopes'
+// order.
+func uniqueScopes(requested []string) []string {
+	scopes := []string{}
+	for _, scope := range models.AccessTokenScopes {
+		for _, r := range requested {
+			if r == scope {
+				scopes = append(scopes, scope)
+				break
+			}
+		}
+	}
+	return scopes
+}
+
+// DeleteAccessToken revokes one of the current user's personal access
+// tokens.
+func DeleteAccessToken(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	result := config.DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).Delete(&models.PersonalAccessToken{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API token"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API token not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API token revoked"})
+}
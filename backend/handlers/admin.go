@@ -1,12 +1,17 @@
 package handlers
 
 import (
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
 	"taskmanager-backend/backend/config"
+	"taskmanager-backend/backend/ledger"
 	"taskmanager-backend/backend/models"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 func GetAllUsers(c *gin.Context) {
@@ -41,6 +46,7 @@ func UpdateUserStatus(c *gin.Context) {
 		Role               *string `json:"role"`
 		SubscriptionStatus *string `json:"subscription_status"`
 		SubscriptionPlan   *string `json:"subscription_plan"`
+		CreditHold         *bool   `json:"credit_hold"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -54,20 +60,35 @@ func UpdateUserStatus(c *gin.Context) {
 		return
 	}
 
+	// Only write the fields being changed, so a concurrent credit change
+	// isn't overwritten with the balance read above.
+	updates := map[string]interface{}{}
 	if input.Verified != nil {
-		user.Verified = *input.Verified
+		updates["verified"] = *input.Verified
 	}
 	if input.Role != nil {
-		user.Role = *input.Role
+		updates["role"] = *input.Role
 	}
 	if input.SubscriptionStatus != nil {
-		user.SubscriptionStatus = *input.SubscriptionStatus
+		updates["subscription_status"] = *input.SubscriptionStatus
 	}
 	if input.SubscriptionPlan != nil {
-		user.SubscriptionPlan = *input.SubscriptionPlan
+		updates["subscription_plan"] = *input.SubscriptionPlan
+	}
+	if input.CreditHold != nil {
+		updates["credit_hold"] = *input.CreditHold
 	}
 
-	config.DB.Save(&user)
+	if len(updates) > 0 {
+		if err := config.DB.Model(&user).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+			return
+		}
+	}
+	if err := config.DB.First(&user, user.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
 
 	// Audit Log
 	// log.Printf("Admin updated user %s status: verified=%v, role=%v, sub_status=%v, sub_plan=%v", id, user.Verified, user.Role, user.SubscriptionStatus, user.SubscriptionPlan)
@@ -75,6 +96,54 @@ func UpdateUserStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, user)
 }
 
+// AdminSettings are the application-wide options admins can change.
+type AdminSettings struct {
+	AdminRequireTwoFactor *bool `json:"admin_require_2fa"`
+}
+
+func GetAdminSettings(c *gin.Context) {
+	required, err := models.BoolSetting(config.DB, models.SettingAdminRequireTwoFactor)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch settings"})
+		return
+	}
+	c.JSON(http.StatusOK, AdminSettings{AdminRequireTwoFactor: &required})
+}
+
+// UpdateAdminSettings changes the settings given in the body. Admins can
+// only require two-factor authentication once they use it themselves, so
+// they can't lock everyone, themselves included, out of the admin API.
+func UpdateAdminSettings(c *gin.Context) {
+	var input AdminSettings
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.AdminRequireTwoFactor != nil {
+		if *input.AdminRequireTwoFactor {
+			userID, _ := c.Get("user_id")
+			var admin models.User
+			if err := config.DB.First(&admin, userID).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+				return
+			}
+			if !admin.TwoFactorEnabled {
+				c.JSON(http.StatusConflict, gin.H{"error": "Turn on two-factor authentication for your own account first"})
+				return
+			}
+		}
+
+		setting := models.Setting{Name: models.SettingAdminRequireTwoFactor, Value: strconv.FormatBool(*input.AdminRequireTwoFactor)}
+		if err := config.DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(&setting).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update settings"})
+			return
+		}
+	}
+
+	GetAdminSettings(c)
+}
+
 func GetAllTransactions(c *gin.Context) {
 	var transactions []models.Transaction
 	if err := config.DB.Order("created_at desc").Find(&transactions).Error; err != nil {
@@ -100,39 +169,89 @@ func AddUserCredits(c *gin.Context) {
 		return
 	}
 
+	userID, err := strconv.Atoi(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
 	tx := config.DB.Begin()
 
-	var user models.User
-	if err := tx.First(&user, id).Error; err != nil {
+	user, err := ledger.Credit(tx, uint(userID), input.Amount, "admin_adjustment", "Admin added credits")
+	if err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update credits"})
 		return
 	}
 
-	user.Credits += input.Amount
-	if err := tx.Save(&user).Error; err != nil {
+	tx.Commit()
+
+	// Audit Log
+	log.Printf("Admin added %d credits to user %s", input.Amount, id)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Credits added successfully", "new_balance": user.Credits})
+}
+
+// GetClawbacks lists clawbacks, newest first. ?status=pending_review gives
+// the review queue.
+func GetClawbacks(c *gin.Context) {
+	query := config.DB.Order("created_at desc")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var clawbacks []models.Clawback
+	if err := query.Find(&clawbacks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch clawbacks"})
+		return
+	}
+	c.JSON(http.StatusOK, clawbacks)
+}
+
+// ResolveClawback settles a clawback waiting for review: "recover" charges
+// the shortfall to the user after all, "waive" writes it off.
+func ResolveClawback(c *gin.Context) {
+	var input struct {
+		Action string `json:"action" binding:"required,oneof=recover waive"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx := config.DB.Begin()
+
+	var clawback models.Clawback
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&clawback, c.Param("id")).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update credits"})
+		c.JSON(http.StatusNotFound, gin.H{"error": "Clawback not found"})
+		return
+	}
+	if clawback.Status != models.ClawbackPendingReview {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "Clawback is not waiting for review"})
 		return
 	}
 
-	// Log Transaction
-	transaction := models.Transaction{
-		UserID:      user.ID,
-		Amount:      input.Amount,
-		Type:        "admin_adjustment",
-		Description: "Admin added credits",
+	var err error
+	if input.Action == "recover" {
+		err = ledger.RecoverShortfall(tx, &clawback)
+	} else {
+		err = tx.Model(&clawback).Update("status", models.ClawbackWaived).Error
 	}
-	if err := tx.Create(&transaction).Error; err != nil {
+	if err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create transaction record"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve clawback"})
 		return
 	}
 
 	tx.Commit()
 
-	// Audit Log
-	log.Printf("Admin added %d credits to user %s", input.Amount, id)
+	log.Printf("Admin resolved clawback %d: %s", clawback.ID, input.Action)
 
-	c.JSON(http.StatusOK, gin.H{"message": "Credits added successfully", "new_balance": user.Credits})
+	c.JSON(http.StatusOK, clawback)
 }
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"taskmanager-backend/backend/config"
+	"taskmanager-backend/backend/models"
+	"taskmanager-backend/backend/storage"
+	"taskmanager-backend/backend/trash"
+	"taskmanager-backend/backend/utils"
+
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// sniffLength is how much of an upload http.DetectContentType looks at.
+const sniffLength = 512
+
+// multipartOverhead is the slack allowed on top of the largest file for
+// the rest of an upload request.
+const multipartOverhead = 1 << 20
+
+func GetAttachments(c *gin.Context) {
+	task, err := findTask(c, config.DB, c.Param("id"), models.WorkspaceReadRoles)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+
+	var attachments []models.Attachment
+	if err := config.DB.Where("task_id = ?", task.ID).Order("created_at, id").Find(&attachments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attachments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, attachments)
+}
+
+// UploadAttachment stores the multipart "file" field on a task, within the
+// uploader's plan quota.
+func UploadAttachment(c *gin.Context) {
+	task, ok := taskForWrite(c, config.DB, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+	quota := models.StorageQuotaFor(user.SubscriptionPlan)
+
+	// Leave room for the multipart framing around the file itself
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, quota.MaxFileSize+multipartOverhead)
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large", "max_file_size": quota.MaxFileSize})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A multipart \"file\" field is required"})
+		return
+	}
+	defer file.Close()
+
+	if header.Size > quota.MaxFileSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large", "max_file_size": quota.MaxFileSize})
+		return
+	}
+
+	// Turn away uploads that clearly won't fit before storing anything; the
+	// quota is enforced for real when the attachment is saved.
+	used, err := storageUsed(config.DB, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check storage quota"})
+		return
+	}
+	if used+header.Size > quota.MaxTotalSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Storage quota exceeded", "used": used, "max_total_size": quota.MaxTotalSize})
+		return
+	}
+
+	// Trust the bytes, not the client's Content-Type
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read upload"})
+		return
+	}
+	head = head[:n]
+
+	token, err := utils.NewOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file"})
+		return
+	}
+
+	attachment := models.Attachment{
+		TaskID:      task.ID,
+		UserID:      user.ID,
+		FileName:    attachmentName(header.Filename),
+		ContentType: http.DetectContentType(head),
+		Size:        header.Size,
+		StorageKey:  fmt.Sprintf("tasks/%d/%s", task.ID, token),
+	}
+
+	body := io.MultiReader(bytes.NewReader(head), file)
+	if err := config.Storage.Put(c.Request.Context(), attachment.StorageKey, body, attachment.Size, attachment.ContentType); err != nil {
+		log.Printf("Failed to store attachment for task %d: %v", task.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file"})
+		return
+	}
+
+	// Locking the user row serialises this user's uploads, so two at once
+	// can't both fit under the quota on their own and overrun it together.
+	tx := config.DB.Begin()
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, user.ID).Error; err != nil {
+		tx.Rollback()
+		removeStoredFiles([]string{attachment.StorageKey})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save attachment"})
+		return
+	}
+	quota = models.StorageQuotaFor(user.SubscriptionPlan)
+	used, err = storageUsed(tx, user.ID)
+	if err != nil {
+		tx.Rollback()
+		removeStoredFiles([]string{attachment.StorageKey})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check storage quota"})
+		return
+	}
+	if used+attachment.Size > quota.MaxTotalSize {
+		tx.Rollback()
+		removeStoredFiles([]string{attachment.StorageKey})
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Storage quota exceeded", "used": used, "max_total_size": quota.MaxTotalSize})
+		return
+	}
+	if err := tx.Create(&attachment).Error; err != nil {
+		tx.Rollback()
+		removeStoredFiles([]string{attachment.StorageKey})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save attachment"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		removeStoredFiles([]string{attachment.StorageKey})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save attachment"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, attachment)
+}
+
+// storageUsed is the total size of the attachments a user has uploaded.
+func storageUsed(db *gorm.DB, userID uint) (int64, error) {
+	var used int64
+	err := db.Model(&models.Attachment{}).Where("user_id = ?", userID).
+		Select("COALESCE(SUM(size), 0)").Scan(&used).Error
+	return used, err
+}
+
+// findTaskAttachment loads one of task's attachments by the
+// :attachment_id parameter.
+func findTaskAttachment(c *gin.Context, task models.Task) (models.Attachment, bool) {
+	var attachment models.Attachment
+	if err := config.DB.Where("id = ? AND task_id = ?", c.Param("attachment_id"), task.ID).First(&attachment).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return attachment, false
+	}
+	return attachment, true
+}
+
+func DownloadAttachment(c *gin.Context) {
+	task, err := findTask(c, config.DB, c.Param("id"), models.WorkspaceReadRoles)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+	attachment, ok := findTaskAttachment(c, task)
+	if !ok {
+		return
+	}
+
+	body, err := config.Storage.Open(c.Request.Context(), attachment.StorageKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File is missing from storage"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer body.Close()
+
+	// Always download rather than render, so uploaded HTML or SVG can't
+	// run in our origin
+	c.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, body, map[string]string{
+		"Content-Disposition":    mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName}),
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
+func DeleteAttachment(c *gin.Context) {
+	task, ok := taskForWrite(c, config.DB, c.Param("id"))
+	if !ok {
+		return
+	}
+	attachment, ok := findTaskAttachment(c, task)
+	if !ok {
+		return
+	}
+
+	if err := config.DB.Delete(&attachment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete attachment"})
+		return
+	}
+	removeStoredFiles([]string{attachment.StorageKey})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Attachment deleted successfully"})
+}
+
+// removeStoredFiles deletes blobs whose rows are already gone.
+func removeStoredFiles(keys []string) {
+	trash.RemoveFiles(config.Storage, keys)
+}
+
+// attachmentName keeps the base name of an uploaded file, bounded in length.
+func attachmentName(name string) string {
+	name = strings.TrimSpace(filepath.Base(strings.ReplaceAll(name, "\\", "/")))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	if runes := []rune(name); len(runes) > 255 {
+		name = string(runes[:255])
+	}
+	if name == "" || name == "." || name == ".." || name == "/" {
+		return "file"
+	}
+	return name
+}
@@ -1,9 +1,12 @@
 package handlers
 
 import (
+	"errors"
+	"log"
 	"net/http"
 	"taskmanager-backend/backend/config"
 	"taskmanager-backend/backend/models"
+	"taskmanager-backend/backend/sessions"
 	"taskmanager-backend/backend/utils"
 
 	"github.com/gin-gonic/gin"
@@ -34,19 +37,31 @@ func Register(c *gin.Context) {
 	}
 	u.Password = hashedPassword
 
-	if err := config.DB.Create(&u).Error; err != nil {
+	// The user starts with the column default, recorded as a sign-up bonus
+	// so the balance matches the ledger.
+	tx := config.DB.Begin()
+	if err := tx.Create(&u).Error; err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Email already exists"})
 		return
 	}
 
-	// Log initial credits transaction
 	initialTransaction := models.Transaction{
 		UserID:      u.ID,
-		Amount:      5,
+		Amount:      u.Credits,
 		Type:        "bonus",
 		Description: "Initial sign-up credits",
 	}
-	config.DB.Create(&initialTransaction)
+	if err := tx.Create(&initialTransaction).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create user"})
+		return
+	}
+	tx.Commit()
+
+	if err := sendVerificationEmail(c.Request.Context(), u); err != nil {
+		log.Printf("Could not send verification email to user %d: %v", u.ID, err)
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Registration success"})
 }
@@ -75,13 +90,95 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	token, err := utils.GenerateToken(u.ID)
+	// With two-factor authentication on, the password only earns a
+	// pending token; LoginTwoFactor issues the session.
+	if u.TwoFactorEnabled {
+		c.JSON(http.StatusOK, gin.H{
+			"mfa_required": true,
+			"mfa_token":    mfaPendingToken(u),
+			"expires_in":   int(mfaTokenLifespan.Seconds()),
+		})
+		return
+	}
+
+	startSession(c, u)
+}
+
+// startSession logs u in and answers with the session's tokens.
+func startSession(c *gin.Context, u models.User) {
+	tokens, err := sessions.Start(config.DB, u.ID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Could not generate token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+		"user":          gin.H{"id": u.ID, "name": u.Name, "email": u.Email, "role": u.Role},
+	})
+}
+
+type RefreshInput struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// RefreshSession trades a refresh token for a new access token and the
+// next refresh token. The old refresh token stops working.
+func RefreshSession(c *gin.Context) {
+	var input RefreshInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": token, "user": gin.H{"id": u.ID, "name": u.Name, "email": u.Email, "role": u.Role}})
+	tokens, err := sessions.Refresh(config.DB, input.RefreshToken)
+	switch {
+	case errors.Is(err, sessions.ErrRefreshTokenReused):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token was already used; the session has been revoked"})
+		return
+	case errors.Is(err, sessions.ErrInvalidRefreshToken):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not refresh session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Logout ends the session the request's access token belongs to.
+func Logout(c *gin.Context) {
+	sessionID, exists := c.Get("session_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := sessions.RevokeSession(config.DB, sessionID.(string)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// LogoutEverywhere ends all of the user's sessions, on every device.
+func LogoutEverywhere(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := sessions.RevokeUser(config.DB, userID.(uint)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out of all sessions"})
 }
 
 func CurrentUser(c *gin.Context) {
@@ -125,8 +222,11 @@ func UpdateProfile(c *gin.Context) {
 		return
 	}
 
+	// Only the fields being changed are written, so credits and other
+	// columns updated meanwhile aren't overwritten with what was read here.
+	updates := map[string]interface{}{}
 	if input.Name != "" {
-		user.Name = input.Name
+		updates["name"] = input.Name
 	}
 
 	if input.Password != "" {
@@ -145,10 +245,16 @@ func UpdateProfile(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not hash password"})
 			return
 		}
-		user.Password = hashedPassword
+		updates["password"] = hashedPassword
 	}
 
-	if err := config.DB.Save(&user).Error; err != nil {
+	if len(updates) > 0 {
+		if err := config.DB.Model(&user).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update profile"})
+			return
+		}
+	}
+	if err := config.DB.First(&user, user.ID).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update profile"})
 		return
 	}
@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"taskmanager-backend/backend/config"
+	"taskmanager-backend/backend/mailer"
+	"taskmanager-backend/backend/middlewares"
+	"taskmanager-backend/backend/models"
+	"taskmanager-backend/backend/totp"
+	"taskmanager-backend/backend/utils"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupAuthRouter wires the auth endpoints behind the real JWT middleware,
+// unlike setupRouter's mock.
+func setupAuthRouter(t *testing.T) *gin.Engine {
+	t.Setenv("API_SECRET", "test-secret")
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/api/auth/login", Login)
+	r.POST("/api/auth/login/2fa", LoginTwoFactor)
+	r.POST("/api/auth/refresh", RefreshSession)
+	r.POST("/api/auth/signup", Register)
+	r.POST("/api/auth/verify-email", VerifyEmail)
+	r.POST("/api/auth/forgot-password", ForgotPassword)
+	r.POST("/api/auth/reset-password", ResetPassword)
+	protected := r.Group("/api", middlewares.JwtAuthMiddleware())
+	protected.GET("/auth/me", CurrentUser)
+	protected.POST("/auth/logout", Logout)
+	protected.POST("/auth/logout-all", LogoutEverywhere)
+	protected.POST("/auth/verify-email/resend", ResendVerification)
+	protected.POST("/subscriptions/purchase", middlewares.RequireScope(models.ScopeBilling), CreatePaymentIntent)
+	protected.GET("/tasks", middlewares.RequireScope(models.ScopeReadTasks), GetTasks)
+	protected.POST("/tasks", middlewares.RequireScope(models.ScopeWriteTasks), CreateTask)
+	account := protected.Group("", middlewares.SessionOnly())
+	account.GET("/auth/tokens", GetAccessTokens)
+	account.POST("/auth/tokens", CreateAccessToken)
+	account.DELETE("/auth/tokens/:id", DeleteAccessToken)
+	protected.POST("/auth/2fa/enroll", EnrollTwoFactor)
+	protected.POST("/auth/2fa/confirm", ConfirmTwoFactor)
+	protected.POST("/auth/2fa/disable", DisableTwoFactor)
+	protected.POST("/auth/2fa/recovery-codes", RegenerateRecoveryCodes)
+	admin := r.Group("/api/admin", middlewares.JwtAuthMiddleware(), middlewares.SessionOnly(), middlewares.AdminAuthMiddleware())
+	admin.GET("/stats", GetAdminStats)
+	admin.GET("/settings", GetAdminSettings)
+	admin.PUT("/settings", UpdateAdminSettings)
+
+	hashed, _ := utils.HashPassword("secret123")
+	config.DB.Model(&models.User{}).Where("id = ?", 1).Update("password", hashed)
+	return r
+}
+
+type authTokens struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+func authRequest(r *gin.Engine, method, url, token, body string) (*httptest.ResponseRecorder, authTokens) {
+	req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var tokens authTokens
+	json.Unmarshal(w.Body.Bytes(), &tokens)
+	return w, tokens
+}
+
+func TestRefreshRotationAndRevocation(t *testing.T) {
+	setupTestDB()
+	r := setupAuthRouter(t)
+	login := func() authTokens {
+		w, tokens := authRequest(r, "POST", "/api/auth/login", "", `{"email": "test@example.com", "password": "secret123"}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		return tokens
+	}
+	refresh := func(token string) (*httptest.ResponseRecorder, authTokens) {
+		return authRequest(r, "POST", "/api/auth/refresh", "", `{"refresh_token": "`+token+`"}`)
+	}
+	me := func(token string) int {
+		w, _ := authRequest(r, "GET", "/api/auth/me", token, "")
+		return w.Code
+	}
+
+	// Refreshing rotates the refresh token.
+	first := login()
+	w, second := refresh(first.RefreshToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+	assert.Equal(t, http.StatusOK, me(second.Token))
+
+	// Replaying the old refresh token kills the whole family.
+	w, _ = refresh(first.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, http.StatusUnauthorized, me(second.Token))
+	w, _ = refresh(second.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Logout only ends its own session.
+	phone, laptop := login(), login()
+	w, _ = authRequest(r, "POST", "/api/auth/logout", phone.Token, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusUnauthorized, me(phone.Token))
+	assert.Equal(t, http.StatusOK, me(laptop.Token))
+
+	// Logging out everywhere ends the rest.
+	w, _ = authRequest(r, "POST", "/api/auth/logout-all", laptop.Token, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusUnauthorized, me(laptop.Token))
+	w, _ = refresh(laptop.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestEmailVerification(t *testing.T) {
+	setupTestDB()
+	r := setupAuthRouter(t)
+	outbox, _ := mailer.NewOutbox(t.TempDir(), "App <app@example.com>")
+	config.Mailer = outbox
+
+	w, _ := authRequest(r, "POST", "/api/auth/signup", "", `{"name": "Ann", "email": "ann@example.com", "password": "secret123"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	_, session := authRequest(r, "POST", "/api/auth/login", "", `{"email": "ann@example.com", "password": "secret123"}`)
+
+	// Unverified accounts can't buy credits or go past the task cap.
+	w, _ = authRequest(r, "POST", "/api/subscriptions/purchase", session.Token, `{"credits": 10}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	var ann models.User
+	config.DB.Where("email = ?", "ann@example.com").First(&ann)
+	config.DB.Model(&ann).UpdateColumn("credits", 100)
+	for i := 0; i < models.UnverifiedTaskLimit; i++ {
+		w, _ = authRequest(r, "POST", "/api/tasks", session.Token, `{"title": "Existing"}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+	}
+	// Trashing a task doesn't make room for another.
+	var trashed models.Task
+	config.DB.Where("user_id = ?", ann.ID).First(&trashed)
+	config.DB.Delete(&trashed)
+	w, _ = authRequest(r, "POST", "/api/tasks", session.Token, `{"title": "One more"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Resending is throttled.
+	w, _ = authRequest(r, "POST", "/api/auth/verify-email/resend", session.Token, "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	messages, err := outbox.Messages()
+	assert.NoError(t, err)
+	assert.Len(t, messages, 1)
+	assert.Equal(t, "ann@example.com", messages[0].To)
+	token := regexp.MustCompile(`token=([^\s]+)`).FindStringSubmatch(messages[0].Text)[1]
+
+	// Tampered, expired or rebound links don't work.
+	w, _ = authRequest(r, "POST", "/api/auth/verify-email", "", `{"token": "`+token+`x"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	expired := utils.SignLinkToken(verifyEmailPurpose, ann.ID, ann.Email, time.Now().Add(-time.Minute))
+	w, _ = authRequest(r, "POST", "/api/auth/verify-email", "", `{"token": "`+expired+`"}`)
+	assert.Equal(t, http.StatusGone, w.Code)
+	other := utils.SignLinkToken(verifyEmailPurpose, ann.ID, "old@example.com", time.Now().Add(time.Hour))
+	w, _ = authRequest(r, "POST", "/api/auth/verify-email", "", `{"token": "`+other+`"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w, _ = authRequest(r, "POST", "/api/auth/verify-email", "", `{"token": "`+token+`"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	config.DB.First(&ann, ann.ID)
+	assert.True(t, ann.Verified)
+
+	w, _ = authRequest(r, "POST", "/api/tasks", session.Token, `{"title": "One more"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestPasswordReset(t *testing.T) {
+	setupTestDB()
+	r := setupAuthRouter(t)
+	outbox, _ := mailer.NewOutbox(t.TempDir(), "App <app@example.com>")
+	config.Mailer = outbox
+	_, session := authRequest(r, "POST", "/api/auth/login", "", `{"email": "test@example.com", "password": "secret123"}`)
+
+	// Known and unknown addresses get the same answer.
+	known, _ := authRequest(r, "POST", "/api/auth/forgot-password", "", `{"email": "test@example.com"}`)
+	unknown, _ := authRequest(r, "POST", "/api/auth/forgot-password", "", `{"email": "nobody@example.com"}`)
+	assert.Equal(t, http.StatusOK, known.Code)
+	assert.Equal(t, known.Body.String(), unknown.Body.String())
+
+	passwordResets.Wait()
+	messages, _ := outbox.Messages()
+	assert.Len(t, messages, 1)
+	token := regexp.MustCompile(`token=([^\s]+)`).FindStringSubmatch(messages[0].Text)[1]
+
+	reset := func(password string) int {
+		w, _ := authRequest(r, "POST", "/api/auth/reset-password", "", `{"token": "`+token+`", "password": "`+password+`"}`)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusBadRequest, reset("short"))
+	assert.Equal(t, http.StatusOK, reset("new-secret-1"))
+	assert.Equal(t, http.StatusBadRequest, reset("new-secret-2"))
+
+	// Old sessions are gone; the new password works.
+	w, _ := authRequest(r, "GET", "/api/auth/me", session.Token, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w, _ = authRequest(r, "POST", "/api/auth/refresh", "", `{"refresh_token": "`+session.RefreshToken+`"}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w, _ = authRequest(r, "POST", "/api/auth/login", "", `{"email": "test@example.com", "password": "new-secret-1"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	setupTestDB()
+	r := setupAuthRouter(t)
+	config.DB.Model(&models.User{}).Where("id = ?", 1).Update("role", "admin")
+	credentials := `{"email": "test@example.com", "password": "secret123"}`
+	_, session := authRequest(r, "POST", "/api/auth/login", "", credentials)
+
+	// An admin can't require two-factor authentication before using it.
+	w, _ := authRequest(r, "PUT", "/api/admin/settings", session.Token, `{"admin_require_2fa": true}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Once it is required, admins are kept out until they enable it.
+	config.DB.Create(&models.Setting{Name: models.SettingAdminRequireTwoFactor, Value: "true"})
+	w, _ = authRequest(r, "GET", "/api/admin/stats", session.Token, "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w, _ = authRequest(r, "POST", "/api/auth/2fa/enroll", session.Token, `{"password": "wrong"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w, _ = authRequest(r, "POST", "/api/auth/2fa/enroll", session.Token, `{"password": "secret123"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var enrollment struct {
+		Secret     string `json:"secret"`
+		OTPAuthURL string `json:"otpauth_url"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &enrollment)
+	assert.Contains(t, enrollment.OTPAuthURL, "otpauth://totp/")
+	assert.Contains(t, enrollment.OTPAuthURL, "secret="+enrollment.Secret)
+
+	code := func(offset int64) string {
+		c, _ := totp.Code(enrollment.Secret, totp.Step(time.Now())+offset)
+		return c
+	}
+	w, _ = authRequest(r, "POST", "/api/auth/2fa/confirm", session.Token, `{"code": "`+code(-1)+`"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var confirmed struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &confirmed)
+	assert.Len(t, confirmed.RecoveryCodes, 10)
+
+	w, _ = authRequest(r, "GET", "/api/admin/stats", session.Token, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w, _ = authRequest(r, "PUT", "/api/admin/settings", session.Token, `{"admin_require_2fa": true}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"admin_require_2fa": true}`, w.Body.String())
+
+	// The password alone now only earns a pending token, which isn't a
+	// session.
+	pending := func() string {
+		w, tokens := authRequest(r, "POST", "/api/auth/login", "", credentials)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, tokens.Token)
+		var body struct {
+			MFARequired bool   `json:"mfa_required"`
+			MFAToken    string `json:"mfa_token"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		assert.True(t, body.MFARequired)
+		return body.MFAToken
+	}
+	secondStep := func(mfaToken, code string) (*httptest.ResponseRecorder, authTokens) {
+		return authRequest(r, "POST", "/api/auth/login/2fa", "", `{"mfa_token": "`+mfaToken+`", "code": "`+code+`"}`)
+	}
+	mfaToken := pending()
+	w, _ = authRequest(r, "GET", "/api/auth/me", mfaToken, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w, _ = secondStep(mfaToken, "not-a-code")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w, _ = secondStep(mfaToken, code(0))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Codes and recovery codes work once each.
+	w, _ = secondStep(pending(), code(0))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w, tokens := secondStep(pending(), strings.ToUpper(confirmed.RecoveryCodes[0]))
+	assert.Equal(t, http.StatusOK, w.Code)
+	w, _ = authRequest(r, "GET", "/api/auth/me", tokens.Token, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w, _ = secondStep(pending(), confirmed.RecoveryCodes[0])
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Too many wrong codes lock the second step, even for a right one.
+	mfaToken = pending()
+	for i := 0; i < twoFactorMaxFailures; i++ {
+		secondStep(mfaToken, "not-a-code")
+	}
+	w, _ = secondStep(mfaToken, confirmed.RecoveryCodes[1])
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	config.DB.Model(&models.User{}).Where("id = ?", 1).Update("two_factor_locked_until", nil)
+
+	// Disabling takes the password and a code.
+	w, _ = authRequest(r, "POST", "/api/auth/2fa/disable", tokens.Token, `{"password": "secret123", "code": "not-a-code"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w, _ = authRequest(r, "POST", "/api/auth/2fa/disable", tokens.Token, `{"password": "secret123", "code": "`+confirmed.RecoveryCodes[1]+`"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	_, tokens = authRequest(r, "POST", "/api/auth/login", "", credentials)
+	assert.NotEmpty(t, tokens.Token)
+	w, _ = authRequest(r, "GET", "/api/admin/stats", tokens.Token, "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestPersonalAccessTokens(t *testing.T) {
+	setupTestDB()
+	r := setupAuthRouter(t)
+	config.DB.Model(&models.User{}).Where("id = ?", 1).Update("role", "admin")
+	_, session := authRequest(r, "POST", "/api/auth/login", "", `{"email": "test@example.com", "password": "secret123"}`)
+
+	w, _ := authRequest(r, "POST", "/api/auth/tokens", session.Token, `{"name": "CI", "scopes": ["admin"]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w, created := authRequest(r, "POST", "/api/auth/tokens", session.Token, `{"name": "CI", "scopes": ["read:tasks", "read:tasks"], "expires_in_days": 30}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	pat := created.Token
+	assert.True(t, strings.HasPrefix(pat, models.PersonalAccessTokenPrefix))
+
+	// The token reaches what its scopes allow and nothing else.
+	w, _ = authRequest(r, "GET", "/api/tasks", pat, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w, _ = authRequest(r, "GET", "/api/auth/me", pat, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w, _ = authRequest(r, "POST", "/api/tasks", pat, `{"title": "From CI"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w, _ = authRequest(r, "POST", "/api/subscriptions/purchase", pat, `{"credits": 10}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w, _ = authRequest(r, "POST", "/api/auth/tokens", pat, `{"name": "Escalate", "scopes": ["write:tasks"]}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w, _ = authRequest(r, "GET", "/api/admin/stats", pat, "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Listing shows when it was last used, but never the token.
+	w, _ = authRequest(r, "GET", "/api/auth/tokens", session.Token, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), pat)
+	var list struct {
+		Data []models.PersonalAccessToken `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	assert.Len(t, list.Data, 1)
+	assert.Equal(t, []string{models.ScopeReadTasks}, list.Data[0].Scopes)
+	assert.NotNil(t, list.Data[0].LastUsedAt)
+	assert.NotNil(t, list.Data[0].ExpiresAt)
+
+	config.DB.Model(&models.PersonalAccessToken{}).Where("id = ?", list.Data[0].ID).Update("expires_at", time.Now().Add(-time.Minute))
+	w, _ = authRequest(r, "GET", "/api/tasks", pat, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w, _ = authRequest(r, "DELETE", fmt.Sprintf("/api/auth/tokens/%d", list.Data[0].ID), session.Token, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w, _ = authRequest(r, "GET", "/api/auth/me", pat, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
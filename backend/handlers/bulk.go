@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"taskmanager-backend/backend/config"
+	"taskmanager-backend/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+)
+
+// maxBulkOperations caps the size of one bulk request.
+const maxBulkOperations = 200
+
+// Bulk operation kinds.
+const (
+	BulkOpCreate = "create" // task: CreateTaskInput
+	BulkOpUpdate = "update" // id, task: JSON Merge Patch, as for PATCH
+	BulkOpStatus = "status" // id, status
+	BulkOpDelete = "delete" // id, children: orphan (default) or cascade
+)
+
+type BulkOperation struct {
+	Op       string          `json:"op" binding:"required"`
+	ID       uint            `json:"id"`
+	Task     json.RawMessage `json:"task"`
+	Status   string          `json:"status"`
+	Children string          `json:"children"`
+}
+
+type BulkTaskInput struct {
+	Operations []BulkOperation `json:"operations" binding:"required,min=1"`
+	// AllOrNothing rolls back every operation if any of them fails.
+	// Otherwise failed operations are skipped and the rest are kept.
+	AllOrNothing bool `json:"all_or_nothing"`
+}
+
+// BulkResult is the outcome of one operation, in request order. Status is
+// the HTTP status the equivalent single-task request would have returned.
+type BulkResult struct {
+	Index   int          `json:"index"`
+	Op      string       `json:"op"`
+	Status  int          `json:"status"`
+	Task    *models.Task `json:"task,omitempty"`
+	ID      uint         `json:"id,omitempty"`
+	Error   string       `json:"error,omitempty"`
+	Details gin.H        `json:"details,omitempty"`
+}
+
+// bulkOutcome is what an applied operation leaves to do after commit.
+type bulkOutcome struct {
+	completed *models.Task // spawn its next occurrence
+}
+
+// BulkTasks applies many task operations in one transaction. Creates are
+// charged like CreateTask, and the caller's balance must cover all of
+// them before anything runs.
+func BulkTasks(c *gin.Context) {
+	var input BulkTaskInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(input.Operations) > maxBulkOperations {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d operations per request", maxBulkOperations)})
+		return
+	}
+
+	creates := 0
+	for _, op := range input.Operations {
+		if op.Op == BulkOpCreate {
+			creates++
+		}
+	}
+	if creates > 0 {
+		userID, _ := c.Get("user_id")
+		var user models.User
+		if err := config.DB.First(&user, userID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+			return
+		}
+		if user.Credits < creates {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient credits", "required": creates, "available": user.Credits})
+			return
+		}
+	}
+
+	results := make([]BulkResult, 0, len(input.Operations))
+	var outcomes []bulkOutcome
+	failed := 0
+
+	tx := config.DB.Begin()
+	for i, op := range input.Operations {
+		savepoint := fmt.Sprintf("bulk_op_%d", i)
+		if !input.AllOrNothing {
+			if err := tx.SavePoint(savepoint).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply operations"})
+				return
+			}
+		}
+
+		result, outcome, terr := applyBulkOperation(c, tx, op)
+		result.Index = i
+		result.Op = op.Op
+		if terr != nil {
+			failed++
+			result.Status = terr.Status
+			result.Error = terr.Error()
+			for k, v := range terr.Body {
+				if k == "error" {
+					continue
+				}
+				if result.Details == nil {
+					result.Details = gin.H{}
+				}
+				result.Details[k] = v
+			}
+			results = append(results, result)
+
+			if input.AllOrNothing {
+				tx.Rollback()
+				c.JSON(http.StatusUnprocessableEntity, gin.H{
+					"error":        "Operation failed; no changes were applied",
+					"failed_index": i,
+					"results":      results,
+				})
+				return
+			}
+			// Without the rollback the failed operation's partial writes
+			// would be committed with the rest, so give up on the batch.
+			if err := tx.RollbackTo(savepoint).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply operations"})
+				return
+			}
+			continue
+		}
+
+		results = append(results, result)
+		outcomes = append(outcomes, outcome)
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply operations"})
+		return
+	}
+
+	// Completed tasks are answered as they are, so link their new
+	// occurrence into the result.
+	for _, outcome := range outcomes {
+		if outcome.completed != nil {
+			outcome.completed.NextOccurrenceID = spawnNextOccurrence(*outcome.completed)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results":   results,
+		"succeeded": len(results) - failed,
+		"failed":    failed,
+	})
+}
+
+func applyBulkOperation(c *gin.Context, tx *gorm.DB, op BulkOperation) (BulkResult, bulkOutcome, *taskError) {
+	var outcome bulkOutcome
+
+	switch op.Op {
+	case BulkOpCreate:
+		var input CreateTaskInput
+		if err := json.Unmarshal(op.Task, &input); err != nil {
+			return BulkResult{}, outcome, newTaskError(http.StatusBadRequest, "Invalid task: "+err.Error())
+		}
+		if err := binding.Validator.ValidateStruct(&input); err != nil {
+			return BulkResult{}, outcome, newTaskError(http.StatusBadRequest, err.Error())
+		}
+		task, terr := createTaskTx(c, tx, input)
+		if terr != nil {
+			return BulkResult{}, outcome, terr
+		}
+		return BulkResult{Status: http.StatusCreated, ID: task.ID, Task: &task}, outcome, nil
+
+	case BulkOpUpdate, BulkOpStatus:
+		task, terr := writableTask(c, tx, op.ID)
+		if terr != nil {
+			return BulkResult{ID: op.ID}, outcome, terr
+		}
+
+		patch := []byte(op.Task)
+		if op.Op == BulkOpStatus {
+			patch, _ = json.Marshal(gin.H{"status": op.Status})
+		}
+		input, terr := mergeTaskPatch(task, patch)
+		if terr != nil {
+			return BulkResult{ID: op.ID}, outcome, terr
+		}
+
+		task, completing, terr := updateTaskTx(c, tx, task, input)
+		if terr != nil {
+			return BulkResult{ID: op.ID}, outcome, terr
+		}
+		if completing {
+			outcome.completed = &task
+		}
+		return BulkResult{Status: http.StatusOK, ID: task.ID, Task: &task}, outcome, nil
+
+	case BulkOpDelete:
+		policy := op.Children
+		if policy == "" {
+			policy = ChildPolicyOrphan
+		}
+		if policy != ChildPolicyOrphan && policy != ChildPolicyCascade {
+			return BulkResult{ID: op.ID}, outcome, newTaskError(http.StatusBadRequest, "children must be 'orphan' or 'cascade'")
+		}
+
+		task, terr := writableTask(c, tx, op.ID)
+		if terr != nil {
+			return BulkResult{ID: op.ID}, outcome, terr
+		}
+		userID, _ := c.Get("user_id")
+		if err := deleteTaskTree(tx, task, policy, userID.(uint)); err != nil {
+			return BulkResult{ID: op.ID}, outcome, newTaskError(http.StatusInternalServerError, "Failed to delete task")
+		}
+		return BulkResult{Status: http.StatusOK, ID: task.ID}, outcome, nil
+
+	default:
+		return BulkResult{ID: op.ID}, outcome, newTaskError(http.StatusBadRequest, fmt.Sprintf("Unknown op %q; use create, update, status or delete", op.Op))
+	}
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"taskmanager-backend/backend/config"
+	"taskmanager-backend/backend/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxCommentLength caps comment bodies, in bytes.
+const maxCommentLength = 10000
+
+type CommentInput struct {
+	Body string `json:"body" binding:"required"` // Markdown
+}
+
+// commentBody trims and checks a submitted body, answering 400 if it is
+// unusable.
+func commentBody(c *gin.Context, input CommentInput) (string, bool) {
+	body := strings.TrimSpace(input.Body)
+	if body == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Comment body is required"})
+		return "", false
+	}
+	if len(body) > maxCommentLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Comment body is too long"})
+		return "", false
+	}
+	return body, true
+}
+
+// findTaskComment loads a comment on a task the caller can see.
+func findTaskComment(c *gin.Context, db *gorm.DB) (models.Task, models.Comment, bool) {
+	var comment models.Comment
+
+	task, err := findTask(c, db, c.Param("id"), models.WorkspaceReadRoles)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return task, comment, false
+	}
+
+	if err := db.Where("id = ? AND task_id = ?", c.Param("comment_id"), task.ID).First(&comment).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return task, comment, false
+	}
+	return task, comment, true
+}
+
+func GetComments(c *gin.Context) {
+	task, err := findTask(c, config.DB, c.Param("id"), models.WorkspaceReadRoles)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+
+	var comments []models.Comment
+	if err := config.DB.Where("task_id = ?", task.ID).Order("created_at, id").Find(&comments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch comments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, comments)
+}
+
+// CreateComment adds a comment to a task. Anyone who can see the task,
+// including workspace viewers, may comment on it.
+func CreateComment(c *gin.Context) {
+	task, err := findTask(c, config.DB, c.Param("id"), models.WorkspaceReadRoles)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+
+	var input CommentInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	body, ok := commentBody(c, input)
+	if !ok {
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	comment := models.Comment{
+		TaskID: task.ID,
+		UserID: userID.(uint),
+		Body:   body,
+	}
+
+	if err := config.DB.Create(&comment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create comment"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, comment)
+}
+
+// UpdateComment replaces the body of the caller's own comment, keeping the
+// previous body as a revision.
+func UpdateComment(c *gin.Context) {
+	_, comment, ok := findTaskComment(c, config.DB)
+	if !ok {
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	if comment.UserID != userID.(uint) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only edit your own comments"})
+		return
+	}
+
+	var input CommentInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	body, ok := commentBody(c, input)
+	if !ok {
+		return
+	}
+	if body == comment.Body {
+		c.JSON(http.StatusOK, comment)
+		return
+	}
+
+	now := time.Now()
+	revision := models.CommentRevision{
+		CommentID:  comment.ID,
+		Body:       comment.Body,
+		EditedByID: comment.UserID,
+		CreatedAt:  now,
+	}
+	comment.Body = body
+	comment.EditedAt = &now
+
+	tx := config.DB.Begin()
+	if err := tx.Create(&revision).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment"})
+		return
+	}
+	if err := tx.Save(&comment).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment"})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, comment)
+}
+
+// GetCommentHistory lists the earlier bodies of a comment, oldest first.
+func GetCommentHistory(c *gin.Context) {
+	_, comment, ok := findTaskComment(c, config.DB)
+	if !ok {
+		return
+	}
+
+	var revisions []models.CommentRevision
+	if err := config.DB.Where("comment_id = ?", comment.ID).Order("created_at, id").Find(&revisions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch comment history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, revisions)
+}
+
+// DeleteComment removes a comment. Authors can delete their own comments
+// and anyone who can modify the task can moderate its thread.
+func DeleteComment(c *gin.Context) {
+	task, comment, ok := findTaskComment(c, config.DB)
+	if !ok {
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	if comment.UserID != userID.(uint) {
+		if _, err := findUserTask(c, config.DB, task.ID); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only delete your own comments"})
+			return
+		}
+	}
+
+	if err := config.DB.Delete(&comment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete comment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Comment deleted successfully"})
+}
@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"taskmanager-backend/backend/config"
+	"taskmanager-backend/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errDependencyCycle = errors.New("dependency would create a cycle")
+
+// openBlockers returns the live, not yet completed tasks that block taskID.
+func openBlockers(db *gorm.DB, taskID uint) ([]models.Task, error) {
+	var blockers []models.Task
+	err := db.Joins("JOIN task_dependencies ON task_dependencies.blocker_id = tasks.id").
+		Where("task_dependencies.task_id = ?", taskID).
+		Where("tasks.status <> ?", models.StatusCompleted).
+		Find(&blockers).Error
+	return blockers, err
+}
+
+// blockersError returns a 409 if taskID still has open blockers, which
+// means it can't be completed yet.
+func blockersError(db *gorm.DB, taskID uint) *taskError {
+	blockers, err := openBlockers(db, taskID)
+	if err != nil {
+		return newTaskError(http.StatusInternalServerError, "Failed to check dependencies")
+	}
+	if len(blockers) > 0 {
+		ids := make([]uint, len(blockers))
+		for i, b := range blockers {
+			ids[i] = b.ID
+		}
+		return &taskError{Status: http.StatusConflict, Body: gin.H{"error": "Task is blocked by open tasks", "blocked_by": ids}}
+	}
+	return nil
+}
+
+// checkNoDependencyCycle fails if blockerID is already reachable from
+// taskID by following "blocks" edges, since adding blocker -> task would
+// then close a loop.
+func checkNoDependencyCycle(db *gorm.DB, taskID, blockerID uint) error {
+	if taskID == blockerID {
+		return errDependencyCycle
+	}
+	visited := map[uint]bool{taskID: true}
+	frontier := []uint{taskID}
+	for len(frontier) > 0 {
+		var next []uint
+		if err := db.Model(&models.TaskDependency{}).Where("blocker_id IN ?", frontier).Pluck("task_id", &next).Error; err != nil {
+			return err
+		}
+		frontier = frontier[:0:0]
+		for _, id := range next {
+			if id == blockerID {
+				return errDependencyCycle
+			}
+			if !visited[id] {
+				visited[id] = true
+				frontier = append(frontier, id)
+			}
+		}
+	}
+	return nil
+}
+
+func GetTaskDependencies(c *gin.Context) {
+	task, err := findTask(c, config.DB, c.Param("id"), models.WorkspaceReadRoles)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+
+	blockedBy := []models.Task{}
+	if err := scopeTasks(c, config.DB.Model(&models.Task{}), models.WorkspaceReadRoles).Joins("JOIN task_dependencies ON task_dependencies.blocker_id = tasks.id").
+		Where("task_dependencies.task_id = ?", task.ID).Find(&blockedBy).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch dependencies"})
+		return
+	}
+
+	blocks := []models.Task{}
+	if err := scopeTasks(c, config.DB.Model(&models.Task{}), models.WorkspaceReadRoles).Joins("JOIN task_dependencies ON task_dependencies.task_id = tasks.id").
+		Where("task_dependencies.blocker_id = ?", task.ID).Find(&blocks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch dependencies"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"blocked_by": blockedBy, "blocks": blocks})
+}
+
+type AddDependencyInput struct {
+	BlockerID uint `json:"blocker_id" binding:"required"`
+}
+
+func AddTaskDependency(c *gin.Context) {
+	var input AddDependencyInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, ok := taskForWrite(c, config.DB, c.Param("id"))
+	if !ok {
+		return
+	}
+	if !checkIfMatch(c, config.DB, task) {
+		return
+	}
+
+	tx := config.DB.Begin()
+	if err := lockUnchanged(c, tx, task); err != nil {
+		tx.Rollback()
+		respondWriteConflict(c, err, "Failed to add dependency")
+		return
+	}
+
+	if _, err := findUserTask(c, tx, input.BlockerID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Blocking task not found"})
+		return
+	}
+
+	// Lock both ends, lowest id first, so two requests adding opposite
+	// edges between the same tasks can't both pass the cycle check.
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Model(&models.Task{}).
+		Where("id IN ?", []uint{task.ID, input.BlockerID}).Order("id").Pluck("id", &[]uint{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add dependency"})
+		return
+	}
+
+	if err := checkNoDependencyCycle(tx, task.ID, input.BlockerID); err != nil {
+		tx.Rollback()
+		if errors.Is(err, errDependencyCycle) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check dependencies"})
+		return
+	}
+
+	var existing int64
+	tx.Model(&models.TaskDependency{}).Where("task_id = ? AND blocker_id = ?", task.ID, input.BlockerID).Count(&existing)
+	if existing > 0 {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "Dependency already exists"})
+		return
+	}
+
+	dependency := models.TaskDependency{TaskID: task.ID, BlockerID: input.BlockerID}
+	if err := tx.Create(&dependency).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add dependency"})
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusCreated, dependency)
+}
+
+func RemoveTaskDependency(c *gin.Context) {
+	task, ok := taskForWrite(c, config.DB, c.Param("id"))
+	if !ok {
+		return
+	}
+	if !checkIfMatch(c, config.DB, task) {
+		return
+	}
+
+	tx := config.DB.Begin()
+	if err := lockUnchanged(c, tx, task); err != nil {
+		tx.Rollback()
+		respondWriteConflict(c, err, "Failed to remove dependency")
+		return
+	}
+
+	result := tx.Where("task_id = ? AND blocker_id = ?", task.ID, c.Param("blocker_id")).Delete(&models.TaskDependency{})
+	if result.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove dependency"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dependency not found"})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Dependency removed successfully"})
+}
+
+// PlannedTask is an open task in dependency order. Tasks with no open
+// blockers can be started now.
+type PlannedTask struct {
+	models.Task
+	OpenBlockers []uint `json:"open_blockers"`
+	Ready        bool   `json:"ready"`
+}
+
+// GetTaskPlan returns the open tasks the caller can work on (their own and
+// those in workspaces where they can edit), topologically sorted by
+// their dependencies, so every task appears after all of its blockers.
+// Among tasks that are unblocked at the same time, higher priority and
+// earlier due dates come first.
+func GetTaskPlan(c *gin.Context) {
+	if _, exists := c.Get("user_id"); !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var tasks []models.Task
+	query := scopeTasks(c, config.DB.Model(&models.Task{}), models.WorkspaceWriteRoles)
+	if err := query.Where("tasks.status <> ?", models.StatusCompleted).Find(&tasks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tasks"})
+		return
+	}
+
+	byID := map[uint]models.Task{}
+	ids := make([]uint, 0, len(tasks))
+	for _, t := range tasks {
+		byID[t.ID] = t
+		ids = append(ids, t.ID)
+	}
+
+	// Every open blocker counts, including ones the caller can't edit or
+	// even see, since UpdateTask refuses to complete a task behind them.
+	var edges []models.TaskDependency
+	if len(ids) > 0 {
+		err := config.DB.Model(&models.TaskDependency{}).
+			Joins("JOIN tasks ON tasks.id = task_dependencies.blocker_id").
+			Where("task_dependencies.task_id IN ?", ids).
+			Where("tasks.status <> ? AND tasks.deleted_at IS NULL", models.StatusCompleted).
+			Find(&edges).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch dependencies"})
+			return
+		}
+	}
+
+	blockers := map[uint][]uint{}
+	dependents := map[uint][]uint{}
+	remaining := map[uint]int{}
+	for _, e := range edges {
+		blockers[e.TaskID] = append(blockers[e.TaskID], e.BlockerID)
+		if _, planned := byID[e.BlockerID]; planned {
+			dependents[e.BlockerID] = append(dependents[e.BlockerID], e.TaskID)
+			remaining[e.TaskID]++
+		}
+	}
+
+	var queue []uint
+	for _, id := range ids {
+		if remaining[id] == 0 {
+			queue = append(queue, id)
+		}
+	}
+
+	plan := make([]PlannedTask, 0, len(tasks))
+	for len(queue) > 0 {
+		sort.Slice(queue, func(i, j int) bool { return planBefore(byID[queue[i]], byID[queue[j]]) })
+		id := queue[0]
+		queue = queue[1:]
+
+		open := blockers[id]
+		if open == nil {
+			open = []uint{}
+		}
+		plan = append(plan, PlannedTask{Task: byID[id], OpenBlockers: open, Ready: len(open) == 0})
+
+		for _, dep := range dependents[id] {
+			remaining[dep]--
+			if remaining[dep] == 0 {
+				queue = append(queue, dep)
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": plan})
+}
+
+func planBefore(a, b models.Task) bool {
+	rank := taskSortFields["priority"].value
+	if ra, rb := rank(a).(int64), rank(b).(int64); ra != rb {
+		return ra > rb
+	}
+	switch {
+	case a.DueDate != nil && b.DueDate == nil:
+		return true
+	case a.DueDate == nil && b.DueDate != nil:
+		return false
+	case a.DueDate != nil && !a.DueDate.Equal(*b.DueDate):
+		return a.DueDate.Before(*b.DueDate)
+	}
+	return a.ID < b.ID
+}
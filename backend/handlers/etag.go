@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"taskmanager-backend/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// errTaskChanged means a task was modified after an If-Match precondition
+// was checked.
+var errTaskChanged = errors.New("task has changed since it was loaded")
+
+// computeETag returns a strong entity tag for a JSON representation.
+func computeETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagListMatches reports whether an If-Match / If-None-Match header
+// lists etag. Weak comparison ignores W/ prefixes, as If-None-Match
+// requires; If-Match uses strong comparison.
+func etagListMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// respondWithETag writes body as JSON tagged with its ETag, or 304 Not
+// Modified if the client's If-None-Match already has it.
+func respondWithETag(c *gin.Context, status int, body interface{}) {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode response"})
+		return
+	}
+	etag := computeETag(raw)
+	c.Header("ETag", etag)
+
+	if status == http.StatusOK {
+		if header := c.GetHeader("If-None-Match"); header != "" && etagListMatches(header, etag, true) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+	c.Data(status, "application/json; charset=utf-8", raw)
+}
+
+// loadTaskDetail loads a task the way GET /api/tasks/:id shows it, with
+// tags, subtask progress and its comment count. Callers check access.
+func loadTaskDetail(db *gorm.DB, id uint) (models.Task, error) {
+	var task models.Task
+	if err := db.Preload("Tags").First(&task, id).Error; err != nil {
+		return task, err
+	}
+
+	tasks := []models.Task{task}
+	if err := fillProgress(db, tasks); err != nil {
+		return task, err
+	}
+
+	var comments int64
+	if err := db.Model(&models.Comment{}).Where("task_id = ?", id).Count(&comments).Error; err != nil {
+		return task, err
+	}
+	tasks[0].CommentCount = &comments
+	return tasks[0], nil
+}
+
+// taskETag is the ETag GET /api/tasks/:id currently returns for a task.
+func taskETag(db *gorm.DB, id uint) (string, error) {
+	task, err := loadTaskDetail(db, id)
+	if err != nil {
+		return "", err
+	}
+	raw, err := json.Marshal(task)
+	if err != nil {
+		return "", err
+	}
+	return computeETag(raw), nil
+}
+
+// checkIfMatch enforces an If-Match header against the task's current
+// ETag, answering 412 on a mismatch. It reports whether the request may
+// continue.
+func checkIfMatch(c *gin.Context, db *gorm.DB, task models.Task) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		return true
+	}
+
+	etag, err := taskETag(db, task.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check task version"})
+		return false
+	}
+	if !etagListMatches(header, etag, false) {
+		c.Header("ETag", etag)
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Task has been modified; reload it and try again"})
+		return false
+	}
+	return true
+}
+
+// lockUnchanged locks task's row for the rest of tx and fails with
+// errTaskChanged if it was updated after task was loaded. Handlers call it
+// when the client sent If-Match, so a concurrent write between
+// checkIfMatch and the update is still caught.
+func lockUnchanged(c *gin.Context, tx *gorm.DB, task models.Task) error {
+	if c.GetHeader("If-Match") == "" {
+		return nil
+	}
+
+	var current models.Task
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "updated_at").First(&current, task.ID).Error; err != nil {
+		return err
+	}
+	if !current.UpdatedAt.Equal(task.UpdatedAt) {
+		return errTaskChanged
+	}
+	return nil
+}
+
+// respondWriteConflict reports a lockUnchanged failure: 412 if the task
+// changed underneath the request, otherwise a 500 with message.
+func respondWriteConflict(c *gin.Context, err error, message string) {
+	if errors.Is(err, errTaskChanged) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Task has been modified; reload it and try again"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"taskmanager-backend/backend/config"
+	"taskmanager-backend/backend/history"
+	"taskmanager-backend/backend/models"
+	"taskmanager-backend/backend/workflow"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetTaskHistory lists a task's events, oldest first.
+func GetTaskHistory(c *gin.Context) {
+	task, err := findTask(c, config.DB, c.Param("id"), models.WorkspaceReadRoles)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+
+	var events []models.TaskEvent
+	if err := config.DB.Where("task_id = ?", task.ID).Order("id").Find(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+// RevertTask restores a task's tracked fields to how they were right after
+// the given event. The revert is itself recorded, so it can be undone too.
+// Like PUT, it honours If-Match.
+func RevertTask(c *gin.Context) {
+	task, ok := taskForWrite(c, config.DB, c.Param("id"))
+	if !ok {
+		return
+	}
+	if !checkIfMatch(c, config.DB, task) {
+		return
+	}
+
+	eventID, err := strconv.ParseUint(c.Param("event_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
+		return
+	}
+
+	tx := config.DB.Begin()
+	if err := lockUnchanged(c, tx, task); err != nil {
+		tx.Rollback()
+		respondWriteConflict(c, err, "Failed to revert task")
+		return
+	}
+	task, completing, terr := revertTaskTx(c, tx, task.ID, uint(eventID))
+	if terr != nil {
+		tx.Rollback()
+		terr.respond(c)
+		return
+	}
+	tx.Commit()
+
+	if completing {
+		spawnNextOccurrence(task)
+	}
+
+	respondTask(c, http.StatusOK, task.ID)
+}
+
+// revertTaskTx reloads the task in tx and rewinds it to its state after
+// eventID. completing reports whether the revert completed the task.
+func revertTaskTx(c *gin.Context, tx *gorm.DB, taskID, eventID uint) (task models.Task, completing bool, terr *taskError) {
+	if err := tx.First(&task, taskID).Error; err != nil {
+		return task, false, newTaskError(http.StatusNotFound, "Task not found")
+	}
+
+	fields, err := history.StateAt(tx, task, eventID)
+	if err != nil {
+		if errors.Is(err, history.ErrEventNotFound) {
+			return task, false, newTaskError(http.StatusNotFound, "Event not found")
+		}
+		return task, false, newTaskError(http.StatusInternalServerError, "Failed to rebuild task version")
+	}
+
+	// The old version may point at things that have since gone away
+	if fields.ProjectID != nil {
+		if _, err := findUserProject(c, tx, *fields.ProjectID); err != nil {
+			return task, false, newTaskError(http.StatusConflict, "The project of that version no longer exists")
+		}
+	}
+	reparenting := !sameID(fields.ParentID, task.ParentID)
+	if reparenting && fields.ParentID != nil {
+		parent, err := findUserTask(c, tx, *fields.ParentID)
+		if err != nil || !sameID(parent.WorkspaceID, task.WorkspaceID) {
+			return task, false, newTaskError(http.StatusConflict, "The parent task of that version is no longer available")
+		}
+		if err := checkNoCycle(tx, task.ID, *fields.ParentID); err != nil {
+			return task, false, newTaskError(http.StatusConflict, err.Error())
+		}
+	}
+
+	completing = fields.Status == models.StatusCompleted && task.Status != models.StatusCompleted
+	if completing {
+		if e := blockersError(tx, task.ID); e != nil {
+			return task, false, e
+		}
+	}
+
+	before := history.Snapshot(task)
+	if err := workflow.Tasks.Restore(&task, fields.Status, time.Now()); err != nil {
+		return task, false, transitionError(err)
+	}
+	task.Title = fields.Title
+	task.Description = fields.Description
+	task.Priority = fields.Priority
+	task.DueDate = fields.DueDate
+	task.Assignee = fields.Assignee
+	task.ProjectID = fields.ProjectID
+
+	if reparenting {
+		position, err := nextChildPosition(tx, task.UserID, fields.ParentID)
+		if err != nil {
+			return task, false, newTaskError(http.StatusInternalServerError, "Failed to revert task")
+		}
+		task.ParentID = fields.ParentID
+		task.Position = position
+	}
+	if err := tx.Save(&task).Error; err != nil {
+		return task, false, newTaskError(http.StatusInternalServerError, "Failed to revert task")
+	}
+	userID, _ := c.Get("user_id")
+	if err := history.Record(tx, task.ID, userID.(uint), models.TaskEventReverted, history.Diff(&before, history.Snapshot(task))); err != nil {
+		return task, false, newTaskError(http.StatusInternalServerError, "Failed to revert task")
+	}
+
+	return task, completing, nil
+}
+
+// unlinkTasks clears column, parent_id or project_id, on every task where
+// it is id, as when the parent or project is deleted. Each task gets an
+// updated event, so its history still rewinds correctly.
+func unlinkTasks(tx *gorm.DB, column string, id uint, actorID uint) error {
+	var tasks []models.Task
+	if err := tx.Where(column+" = ?", id).Find(&tasks).Error; err != nil {
+		return err
+	}
+
+	for _, task := range tasks {
+		before := history.Snapshot(task)
+		after := before
+		switch column {
+		case "parent_id":
+			after.ParentID = nil
+		case "project_id":
+			after.ProjectID = nil
+		}
+		if err := history.Record(tx, task.ID, actorID, models.TaskEventUpdated, history.Diff(&before, after)); err != nil {
+			return err
+		}
+	}
+
+	return tx.Model(&models.Task{}).Where(column+" = ?", id).Update(column, nil).Error
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"taskmanager-backend/backend/config"
+	"taskmanager-backend/backend/mailer"
+	"taskmanager-backend/backend/models"
+	"taskmanager-backend/backend/sessions"
+	"taskmanager-backend/backend/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// passwordResetLifetime is how long an emailed reset link works.
+	passwordResetLifetime = time.Hour
+	// passwordResetInterval throttles reset emails to one address.
+	passwordResetInterval = time.Minute
+)
+
+type ForgotPasswordInput struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// passwordResets tracks reset emails still being sent in the background.
+var passwordResets sync.WaitGroup
+
+// ForgotPassword emails a reset link if an account uses the address. The
+// response is the same either way, so it can't be used to find accounts.
+// The lookup and the email happen after responding, so neither can the
+// time it takes to answer.
+func ForgotPassword(c *gin.Context) {
+	var input ForgotPasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	email := strings.ToLower(strings.TrimSpace(input.Email))
+	passwordResets.Add(1)
+	go func() {
+		defer passwordResets.Done()
+		var user models.User
+		if err := config.DB.Where("LOWER(email) = ?", email).First(&user).Error; err != nil {
+			return
+		}
+		if err := sendPasswordReset(context.Background(), user); err != nil {
+			log.Printf("Could not send password reset to user %d: %v", user.ID, err)
+		}
+	}()
+
+	c.JSON(http.StatusOK, gin.H{"message": "If an account exists for that email, a reset link has been sent"})
+}
+
+func sendPasswordReset(ctx context.Context, user models.User) error {
+	var recent int64
+	if err := config.DB.Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND created_at > ?", user.ID, time.Now().Add(-passwordResetInterval)).
+		Count(&recent).Error; err != nil {
+		return err
+	}
+	if recent > 0 {
+		return nil
+	}
+
+	token, err := utils.NewOpaqueToken()
+	if err != nil {
+		return err
+	}
+	reset := models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: utils.HashOpaqueToken(token),
+		ExpiresAt: time.Now().Add(passwordResetLifetime),
+	}
+	if err := config.DB.Create(&reset).Error; err != nil {
+		return err
+	}
+
+	link := config.AppURL() + "/reset-password?token=" + url.QueryEscape(token)
+	return config.Mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Text: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password for your account. To choose a new one, "+
+			"open this link within an hour:\n\n%s\n\nIf it wasn't you, ignore this message; your password won't change.\n", user.Name, link),
+	})
+}
+
+type ResetPasswordInput struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+// ResetPassword sets a new password from a reset link. The link works
+// once, and every existing session and API token of the user is ended.
+func ResetPassword(c *gin.Context) {
+	var input ResetPasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hashedPassword, err := utils.HashPassword(input.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not hash password"})
+		return
+	}
+
+	tx := config.DB.Begin()
+
+	var reset models.PasswordResetToken
+	if err := tx.Where("token_hash = ?", utils.HashOpaqueToken(input.Token)).First(&reset).Error; err != nil ||
+		reset.UsedAt != nil || time.Now().After(reset.ExpiresAt) {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset link"})
+		return
+	}
+
+	now := time.Now()
+	claim := tx.Model(&reset).Where("used_at IS NULL").Update("used_at", now)
+	if claim.Error != nil || claim.RowsAffected == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset link"})
+		return
+	}
+
+	// The link proves the user reads this mailbox, so the address is
+	// verified too. Other outstanding links stop working.
+	if err := tx.Model(&models.User{}).Where("id = ?", reset.UserID).
+		UpdateColumns(map[string]interface{}{"password": hashedPassword, "verified": true}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not reset password"})
+		return
+	}
+	if err := tx.Model(&models.PasswordResetToken{}).Where("user_id = ? AND used_at IS NULL", reset.UserID).Update("used_at", now).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not reset password"})
+		return
+	}
+
+	if err := sessions.RevokeUser(tx, reset.UserID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not reset password"})
+		return
+	}
+	// API tokens go too, in case whoever had the account made some.
+	if err := tx.Where("user_id = ?", reset.UserID).Delete(&models.PersonalAccessToken{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not reset password"})
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset; log in with your new password"})
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"taskmanager-backend/backend/config"
+	"taskmanager-backend/backend/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ProjectInput struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	Color       string `json:"color"`
+	Archived    *bool  `json:"archived"`
+}
+
+// findUserProject loads a project by id, restricted to the caller.
+func findUserProject(c *gin.Context, db *gorm.DB, id interface{}) (models.Project, error) {
+	userID, _ := c.Get("user_id")
+
+	var project models.Project
+	err := db.Where("id = ? AND user_id = ?", id, userID).First(&project).Error
+	return project, err
+}
+
+func GetProjects(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	query := config.DB.Where("user_id = ?", userID)
+	if c.Query("include_archived") != "true" {
+		query = query.Where("archived = ?", false)
+	}
+
+	var projects []models.Project
+	if err := query.Order("name").Find(&projects).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch projects"})
+		return
+	}
+
+	c.JSON(http.StatusOK, projects)
+}
+
+func CreateProject(c *gin.Context) {
+	var input ProjectInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	project := models.Project{
+		UserID:      userID.(uint),
+		Name:        strings.TrimSpace(input.Name),
+		Description: input.Description,
+		Color:       input.Color,
+	}
+	if input.Archived != nil {
+		project.Archived = *input.Archived
+	}
+
+	if err := config.DB.Create(&project).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create project"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, project)
+}
+
+func GetProject(c *gin.Context) {
+	project, err := findUserProject(c, config.DB, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, project)
+}
+
+func UpdateProject(c *gin.Context) {
+	project, err := findUserProject(c, config.DB, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+
+	var input ProjectInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	project.Name = strings.TrimSpace(input.Name)
+	project.Description = input.Description
+	project.Color = input.Color
+	if input.Archived != nil {
+		project.Archived = *input.Archived
+	}
+
+	if err := config.DB.Save(&project).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update project"})
+		return
+	}
+
+	c.JSON(http.StatusOK, project)
+}
+
+// DeleteProject removes the project and moves its tasks out of it; the
+// tasks themselves are kept.
+func DeleteProject(c *gin.Context) {
+	project, err := findUserProject(c, config.DB, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	tx := config.DB.Begin()
+	if err := unlinkTasks(tx, "project_id", project.ID, userID.(uint)); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete project"})
+		return
+	}
+	if err := tx.Delete(&project).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete project"})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Project deleted successfully"})
+}
+
+func GetProjectTasks(c *gin.Context) {
+	project, err := findUserProject(c, config.DB, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+
+	params, err := ParseTaskListQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	params.ProjectID = &project.ID
+	params.NoProject = false
+
+	listTasks(c, params)
+}
+
+func GetProjectStats(c *gin.Context) {
+	project, err := findUserProject(c, config.DB, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+
+	var counts []struct {
+		Status string
+		Count  int64
+	}
+	tasks := func() *gorm.DB {
+		return scopeTasks(c, config.DB.Model(&models.Task{}), models.WorkspaceReadRoles).
+			Where("tasks.project_id = ?", project.ID)
+	}
+
+	if err := tasks().
+		Select("tasks.status, COUNT(*) AS count").
+		Group("tasks.status").
+		Scan(&counts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute project stats"})
+		return
+	}
+
+	byStatus := map[string]int64{}
+	for _, s := range models.TaskStatuses {
+		byStatus[s] = 0
+	}
+	var total int64
+	for _, row := range counts {
+		byStatus[row.Status] = row.Count
+		total += row.Count
+	}
+
+	var overdue int64
+	tasks().
+		Where("tasks.status <> ? AND tasks.due_date < ?", models.StatusCompleted, time.Now()).
+		Count(&overdue)
+
+	completionRate := 0.0
+	if total > 0 {
+		completionRate = float64(byStatus[models.StatusCompleted]) / float64(total)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"project_id":      project.ID,
+		"total_tasks":     total,
+		"by_status":       byStatus,
+		"overdue_tasks":   overdue,
+		"completion_rate": completionRate,
+	})
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"taskmanager-backend/backend/config"
+	"taskmanager-backend/backend/search"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SearchTasks(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter q is required"})
+		return
+	}
+
+	limit := defaultTaskPageSize
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		if parsed < maxTaskPageSize {
+			limit = parsed
+		} else {
+			limit = maxTaskPageSize
+		}
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	results, err := search.ForDB(config.DB).Search(config.DB, userID.(uint), q, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search tasks"})
+		return
+	}
+	if results == nil {
+		results = []search.TaskResult{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": results})
+}
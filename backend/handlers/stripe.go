@@ -2,18 +2,23 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"os"
 	"strconv"
 	"taskmanager-backend/backend/config"
+	"taskmanager-backend/backend/ledger"
 	"taskmanager-backend/backend/models"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stripe/stripe-go/v74"
 	"github.com/stripe/stripe-go/v74/paymentintent"
 	"github.com/stripe/stripe-go/v74/webhook"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type PurchaseCreditsInput struct {
@@ -32,6 +37,9 @@ func CreatePaymentIntent(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
+	if !requireVerified(c, "buying credits") {
+		return
+	}
 
 	stripe.Key = os.Getenv("STRIPE_SECRET_KEY")
 
@@ -66,6 +74,10 @@ func CreatePaymentIntent(c *gin.Context) {
 	})
 }
 
+// HandleStripeWebhook applies Stripe events exactly once. Each event ID is
+// stored in the same transaction as its effects, so a redelivered event is
+// acknowledged without being applied again. Internal failures answer 500
+// so that Stripe retries the delivery later.
 func HandleStripeWebhook(c *gin.Context) {
 	const MaxBodyBytes = int64(65536)
 	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, MaxBodyBytes)
@@ -85,57 +97,130 @@ func HandleStripeWebhook(c *gin.Context) {
 		return
 	}
 
-	switch event.Type {
-	case "payment_intent.succeeded":
-		var paymentIntent stripe.PaymentIntent
-		err := json.Unmarshal(event.Data.Raw, &paymentIntent)
-		if err != nil {
+	tx := config.DB.Begin()
+
+	processed := models.StripeEvent{ID: event.ID, Type: string(event.Type)}
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&processed)
+	if result.Error != nil {
+		tx.Rollback()
+		log.Printf("Stripe webhook %s: %v", event.ID, result.Error)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record event"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusOK, gin.H{"status": "duplicate"})
+		return
+	}
+
+	if err := applyStripeEvent(tx, event); err != nil {
+		tx.Rollback()
+		if errors.Is(err, errWebhookPayload) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Error parsing webhook JSON"})
 			return
 		}
+		log.Printf("Stripe webhook %s: %v", event.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process event"})
+		return
+	}
 
-		handlePaymentSuccess(paymentIntent)
+	if err := tx.Commit().Error; err != nil {
+		log.Printf("Stripe webhook %s: %v", event.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process event"})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"status": "success"})
 }
 
-func handlePaymentSuccess(pi stripe.PaymentIntent) {
-	userIDStr := pi.Metadata["user_id"]
-	creditsStr := pi.Metadata["credits"]
+var errWebhookPayload = errors.New("malformed webhook payload")
+
+// applyStripeEvent dispatches event to its handler. Event types we don't
+// handle are acknowledged and ignored.
+func applyStripeEvent(tx *gorm.DB, event stripe.Event) error {
+	switch event.Type {
+	case "payment_intent.succeeded", "payment_intent.payment_failed":
+		var paymentIntent stripe.PaymentIntent
+		if err := decodeStripeObject(event, &paymentIntent); err != nil {
+			return err
+		}
+		if event.Type == "payment_intent.succeeded" {
+			return handlePaymentSuccess(tx, paymentIntent)
+		}
+		return handlePaymentFailed(tx, paymentIntent)
+
+	case "charge.refunded":
+		var charge stripe.Charge
+		if err := decodeStripeObject(event, &charge); err != nil {
+			return err
+		}
+		return handleChargeRefunded(tx, charge)
 
-	if userIDStr == "" || creditsStr == "" {
-		return // Missing metadata
+	case "charge.dispute.created", "charge.dispute.closed":
+		var dispute stripe.Dispute
+		if err := decodeStripeObject(event, &dispute); err != nil {
+			return err
+		}
+		if event.Type == "charge.dispute.created" {
+			return handleDisputeCreated(tx, dispute)
+		}
+		return handleDisputeClosed(tx, dispute)
 	}
+	return nil
+}
 
-	userID, _ := strconv.Atoi(userIDStr)
-	credits, _ := strconv.Atoi(creditsStr)
+func decodeStripeObject(event stripe.Event, v interface{}) error {
+	if err := json.Unmarshal(event.Data.Raw, v); err != nil {
+		return fmt.Errorf("%w: %v", errWebhookPayload, err)
+	}
+	return nil
+}
 
-	tx := config.DB.Begin()
+// handlePaymentSuccess credits the purchase described by a succeeded
+// PaymentIntent, unless that PaymentIntent was credited already. Payments
+// without usable metadata weren't created by CreatePaymentIntent; they are
+// logged and skipped, since retrying won't fix them.
+func handlePaymentSuccess(tx *gorm.DB, pi stripe.PaymentIntent) error {
+	userID, errUser := strconv.Atoi(pi.Metadata["user_id"])
+	credits, errCredits := strconv.Atoi(pi.Metadata["credits"])
+	if errUser != nil || errCredits != nil || userID <= 0 || credits <= 0 {
+		log.Printf("Stripe payment %s has no usable user_id/credits metadata; skipping", pi.ID)
+		return nil
+	}
 
 	var user models.User
 	if err := tx.First(&user, userID).Error; err != nil {
-		tx.Rollback()
-		return
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Stripe payment %s is for unknown user %d; skipping", pi.ID, userID)
+			return nil
+		}
+		return err
 	}
 
-	user.Credits += credits
-	if err := tx.Save(&user).Error; err != nil {
-		tx.Rollback()
-		return
+	payment := models.StripePayment{
+		PaymentIntentID: pi.ID,
+		UserID:          user.ID,
+		Credits:         credits,
+		AmountCents:     pi.AmountReceived,
+	}
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&payment)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil // Credited by an earlier event
 	}
 
-	// Log Transaction
 	transaction := models.Transaction{
-		UserID:      user.ID,
+		UserID:      payment.UserID,
 		Amount:      credits,
 		Type:        "purchase",
 		Description: fmt.Sprintf("Purchased %d credits via Stripe", credits),
+		StripeRef:   pi.ID,
 	}
-	if err := tx.Create(&transaction).Error; err != nil {
-		tx.Rollback()
-		return
+	if _, err := ledger.Post(tx, &transaction); err != nil {
+		return err
 	}
 
-	tx.Commit()
+	return tx.Model(&payment).Update("transaction_id", transaction.ID).Error
 }
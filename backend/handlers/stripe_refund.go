@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"taskmanager-backend/backend/ledger"
+	"taskmanager-backend/backend/models"
+
+	"github.com/stripe/stripe-go/v74"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// findStripePayment locks the credited payment behind a PaymentIntent. It
+// returns nil if the PaymentIntent never turned into credits.
+func findStripePayment(tx *gorm.DB, pi *stripe.PaymentIntent) (*models.StripePayment, error) {
+	if pi == nil || pi.ID == "" {
+		return nil, nil
+	}
+	var payment models.StripePayment
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("payment_intent_id = ?", pi.ID).First(&payment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("Stripe PaymentIntent %s was never credited; skipping", pi.ID)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// creditsFor converts an amount of the payment's money back into the
+// credits it bought, rounding down.
+func creditsFor(payment *models.StripePayment, cents int64) int {
+	if payment.AmountCents <= 0 || cents >= payment.AmountCents {
+		return payment.Credits
+	}
+	return int(int64(payment.Credits) * cents / payment.AmountCents)
+}
+
+// unclaimedCredits is what is left of the payment's credits after earlier
+// refunds and disputes.
+func unclaimedCredits(payment *models.StripePayment) int {
+	return payment.Credits - payment.RefundedCredits - payment.DisputedCredits
+}
+
+// handleChargeRefunded claws back the credits bought with the refunded
+// part of a charge. AmountRefunded is cumulative, so each partial refund
+// only takes what earlier ones didn't.
+func handleChargeRefunded(tx *gorm.DB, charge stripe.Charge) error {
+	payment, err := findStripePayment(tx, charge.PaymentIntent)
+	if payment == nil {
+		return err
+	}
+
+	refunded := min(creditsFor(payment, charge.AmountRefunded)-payment.RefundedCredits, unclaimedCredits(payment))
+	if refunded <= 0 {
+		return nil
+	}
+
+	clawback := models.Clawback{
+		UserID:          payment.UserID,
+		PaymentIntentID: payment.PaymentIntentID,
+		Reason:          models.ClawbackRefund,
+		StripeRef:       charge.ID,
+		Credits:         refunded,
+	}
+	if err := ledger.Clawback(tx, &clawback, ledger.PolicyFromEnv()); err != nil {
+		return err
+	}
+	return tx.Model(payment).Update("refunded_credits", payment.RefundedCredits+refunded).Error
+}
+
+// handleDisputeCreated claws back the disputed credits straight away; the
+// money is withdrawn from our account when the dispute opens.
+func handleDisputeCreated(tx *gorm.DB, dispute stripe.Dispute) error {
+	payment, err := findStripePayment(tx, dispute.PaymentIntent)
+	if payment == nil {
+		return err
+	}
+
+	var seen int64
+	if err := tx.Model(&models.Clawback{}).Where("reason = ? AND stripe_ref = ?", models.ClawbackDispute, dispute.ID).Count(&seen).Error; err != nil {
+		return err
+	}
+	disputed := min(creditsFor(payment, dispute.Amount), unclaimedCredits(payment))
+	if seen > 0 || disputed <= 0 {
+		return nil
+	}
+
+	clawback := models.Clawback{
+		UserID:          payment.UserID,
+		PaymentIntentID: payment.PaymentIntentID,
+		Reason:          models.ClawbackDispute,
+		StripeRef:       dispute.ID,
+		Credits:         disputed,
+	}
+	if err := ledger.Clawback(tx, &clawback, ledger.PolicyFromEnv()); err != nil {
+		return err
+	}
+	return tx.Model(payment).Update("disputed_credits", payment.DisputedCredits+disputed).Error
+}
+
+// handleDisputeClosed gives the credits back when we win the dispute. A
+// lost dispute needs nothing more: the clawback already happened.
+func handleDisputeClosed(tx *gorm.DB, dispute stripe.Dispute) error {
+	if dispute.Status != stripe.DisputeStatusWon && dispute.Status != stripe.DisputeStatusWarningClosed {
+		return nil
+	}
+
+	var clawback models.Clawback
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("reason = ? AND stripe_ref = ? AND status <> ?", models.ClawbackDispute, dispute.ID, models.ClawbackReversed).
+		First(&clawback).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if clawback.Charged > 0 {
+		transaction := models.Transaction{
+			UserID:      clawback.UserID,
+			Amount:      clawback.Charged,
+			Type:        "dispute_reversal",
+			Description: fmt.Sprintf("Dispute won; returned %d credits", clawback.Charged),
+			StripeRef:   clawback.PaymentIntentID,
+		}
+		if _, err := ledger.Post(tx, &transaction); err != nil {
+			return err
+		}
+	}
+	if err := tx.Model(&clawback).Update("status", models.ClawbackReversed).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.StripePayment{}).Where("payment_intent_id = ?", clawback.PaymentIntentID).
+		UpdateColumn("disputed_credits", gorm.Expr("disputed_credits - ?", clawback.Credits)).Error
+}
+
+// handlePaymentFailed records a failed purchase in the user's transaction
+// history. No credits were granted, so nothing needs compensating.
+func handlePaymentFailed(tx *gorm.DB, pi stripe.PaymentIntent) error {
+	userID, errUser := strconv.Atoi(pi.Metadata["user_id"])
+	credits, errCredits := strconv.Atoi(pi.Metadata["credits"])
+	if errUser != nil || errCredits != nil {
+		log.Printf("Stripe payment %s has no usable user_id/credits metadata; skipping", pi.ID)
+		return nil
+	}
+
+	var user models.User
+	if err := tx.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Stripe payment %s is for unknown user %d; skipping", pi.ID, userID)
+			return nil
+		}
+		return err
+	}
+
+	description := fmt.Sprintf("Payment for %d credits failed", credits)
+	if pi.LastPaymentError != nil && pi.LastPaymentError.Msg != "" {
+		description += ": " + pi.LastPaymentError.Msg
+	}
+	return tx.Create(&models.Transaction{
+		UserID:      user.ID,
+		Amount:      0,
+		Type:        "payment_failed",
+		Description: description,
+		StripeRef:   pi.ID,
+	}).Error
+}
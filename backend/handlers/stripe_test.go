@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"taskmanager-backend/backend/config"
+	"taskmanager-backend/backend/ledger"
+	"taskmanager-backend/backend/models"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stripe/stripe-go/v74/webhook"
+)
+
+const testWebhookSecret = "whsec_test"
+
+// deliverWebhook posts the event in testdata/stripe/<fixture>.json under
+// the given event ID, signed the way Stripe signs deliveries. overrides
+// replace fields of the event's data object.
+func deliverWebhook(t *testing.T, r *gin.Engine, fixture, eventID string, overrides map[string]interface{}) *httptest.ResponseRecorder {
+	raw, err := os.ReadFile("testdata/stripe/" + fixture + ".json")
+	assert.NoError(t, err)
+	var event map[string]interface{}
+	assert.NoError(t, json.Unmarshal(raw, &event))
+	event["id"] = eventID
+	object := event["data"].(map[string]interface{})["object"].(map[string]interface{})
+	for field, value := range overrides {
+		object[field] = value
+	}
+	payload, _ := json.Marshal(event)
+
+	signed := webhook.GenerateTestSignedPayload(&webhook.UnsignedPayload{
+		Payload:   payload,
+		Secret:    testWebhookSecret,
+		Timestamp: time.Now(),
+	})
+	req, _ := http.NewRequest("POST", "/api/webhook", bytes.NewReader(signed.Payload))
+	req.Header.Set("Stripe-Signature", signed.Header)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestStripeWebhookCreditsOnce(t *testing.T) {
+	setupTestDB()
+	r := setupRouter()
+	t.Setenv("STRIPE_WEBHOOK_SECRET", testWebhookSecret)
+
+	credits := func() int {
+		var user models.User
+		config.DB.First(&user, 1)
+		return user.Credits
+	}
+
+	assert.Equal(t, http.StatusOK, deliverWebhook(t, r, "payment_intent_succeeded", "evt_1", nil).Code)
+	assert.Equal(t, 15, credits())
+
+	// A redelivery, or another event for the same PaymentIntent, is a no-op.
+	w := deliverWebhook(t, r, "payment_intent_succeeded", "evt_1", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "duplicate")
+	assert.Equal(t, http.StatusOK, deliverWebhook(t, r, "payment_intent_succeeded", "evt_2", nil).Code)
+	assert.Equal(t, 15, credits())
+
+	var purchase models.Transaction
+	config.DB.Where("type = ?", "purchase").First(&purchase)
+	assert.Equal(t, "pi_purchase", purchase.StripeRef)
+
+	// Bad signatures are rejected; internal failures ask Stripe to retry
+	// and leave the event unrecorded.
+	t.Setenv("STRIPE_WEBHOOK_SECRET", "whsec_other")
+	assert.Equal(t, http.StatusBadRequest, deliverWebhook(t, r, "payment_intent_succeeded", "evt_3", nil).Code)
+	t.Setenv("STRIPE_WEBHOOK_SECRET", testWebhookSecret)
+
+	config.DB.Exec("DELETE FROM stripe_payments")
+	config.DB.Migrator().DropTable(&models.Transaction{})
+	assert.Equal(t, http.StatusInternalServerError, deliverWebhook(t, r, "payment_intent_succeeded", "evt_4", nil).Code)
+	var recorded int64
+	config.DB.Model(&models.StripeEvent{}).Where("id = ?", "evt_4").Count(&recorded)
+	assert.Equal(t, int64(0), recorded)
+}
+
+func performRequest(r *gin.Engine, method, url, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// balance returns the user's spendable credits, what they owe and whether
+// their credits are on hold.
+func balance(t *testing.T) (int, int, bool) {
+	var user models.User
+	assert.NoError(t, config.DB.First(&user, 1).Error)
+	return user.Credits, user.CreditsOwed, user.CreditHold
+}
+
+// buyAndSpend credits the fixture purchase of 10 credits and spends 12 of
+// the 15 the user then has.
+func buyAndSpend(t *testing.T, r *gin.Engine) {
+	config.DB.Create(&models.Transaction{UserID: 1, Amount: 5, Type: "bonus"}) // setupTestDB's starting balance
+	assert.Equal(t, http.StatusOK, deliverWebhook(t, r, "payment_intent_succeeded", "evt_buy", nil).Code)
+	_, err := ledger.Debit(config.DB, 1, 12, "usage", "Spent")
+	assert.NoError(t, err)
+}
+
+func assertReconciles(t *testing.T) {
+	mismatches, err := ledger.Reconcile(config.DB)
+	assert.NoError(t, err)
+	assert.Empty(t, mismatches)
+}
+
+func TestStripeRefundsClawBackCredits(t *testing.T) {
+	setupTestDB()
+	r := setupRouter()
+	t.Setenv("STRIPE_WEBHOOK_SECRET", testWebhookSecret)
+	t.Setenv("STRIPE_CLAWBACK_POLICY", "hold")
+	buyAndSpend(t, r)
+
+	// Half the payment is refunded: 5 credits back, only 3 are left.
+	assert.Equal(t, http.StatusOK, deliverWebhook(t, r, "charge_refunded", "evt_refund_1", map[string]interface{}{"amount_refunded": 250}).Code)
+	credits, owed, hold := balance(t)
+	assert.Equal(t, []interface{}{0, 2, true}, []interface{}{credits, owed, hold})
+	assertReconciles(t)
+
+	w := performRequest(r, "POST", "/api/tasks", `{"title": "On hold"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// The rest is refunded; the cumulative amount only claws back the new part.
+	assert.Equal(t, http.StatusOK, deliverWebhook(t, r, "charge_refunded", "evt_refund_2", nil).Code)
+	assert.Equal(t, http.StatusOK, deliverWebhook(t, r, "charge_refunded", "evt_refund_3", nil).Code)
+	credits, owed, _ = balance(t)
+	assert.Equal(t, 0, credits)
+	assert.Equal(t, 7, owed)
+
+	// New credits repay the debt first and lift the hold.
+	assert.Equal(t, http.StatusOK, performRequest(r, "POST", "/api/admin/users/1/credits", `{"amount": 10}`).Code)
+	credits, owed, hold = balance(t)
+	assert.Equal(t, []interface{}{3, 0, false}, []interface{}{credits, owed, hold})
+	assertReconciles(t)
+}
+
+func TestStripeDisputesAndFailedPayments(t *testing.T) {
+	setupTestDB()
+	r := setupRouter()
+	t.Setenv("STRIPE_WEBHOOK_SECRET", testWebhookSecret)
+	t.Setenv("STRIPE_CLAWBACK_POLICY", "review")
+	buyAndSpend(t, r)
+
+	// The review policy takes what it can and queues the rest.
+	assert.Equal(t, http.StatusOK, deliverWebhook(t, r, "charge_dispute_created", "evt_dispute", nil).Code)
+	var clawback models.Clawback
+	config.DB.Where("stripe_ref = ?", "dp_purchase").First(&clawback)
+	assert.Equal(t, models.ClawbackPendingReview, clawback.Status)
+	assert.Equal(t, 3, clawback.Recovered)
+	assert.Equal(t, 7, clawback.Shortfall)
+	credits, owed, hold := balance(t)
+	assert.Equal(t, []interface{}{0, 0, false}, []interface{}{credits, owed, hold})
+
+	w := performRequest(r, "POST", fmt.Sprintf("/api/admin/clawbacks/%d/resolve", clawback.ID), `{"action": "recover"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	_, owed, _ = balance(t)
+	assert.Equal(t, 7, owed)
+	assertReconciles(t)
+
+	// Winning the dispute returns everything that was charged.
+	assert.Equal(t, http.StatusOK, deliverWebhook(t, r, "charge_dispute_closed", "evt_won", nil).Code)
+	credits, owed, _ = balance(t)
+	assert.Equal(t, 3, credits)
+	assert.Equal(t, 0, owed)
+	config.DB.First(&clawback, clawback.ID)
+	assert.Equal(t, models.ClawbackReversed, clawback.Status)
+	assertReconciles(t)
+
+	// A failed payment grants nothing but shows up in the history.
+	assert.Equal(t, http.StatusOK, deliverWebhook(t, r, "payment_intent_payment_failed", "evt_failed", nil).Code)
+	var failed models.Transaction
+	assert.NoError(t, config.DB.Where("type = ?", "payment_failed").First(&failed).Error)
+	assert.Equal(t, 0, failed.Amount)
+	assert.Equal(t, "pi_failed", failed.StripeRef)
+	assert.Contains(t, failed.Description, "declined")
+	credits, _, _ = balance(t)
+	assert.Equal(t, 3, credits)
+}
@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"taskmanager-backend/backend/config"
+	"taskmanager-backend/backend/history"
+	"taskmanager-backend/backend/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxTaskDepth bounds ancestor walks so a corrupted hierarchy can't loop forever.
+const maxTaskDepth = 64
+
+const (
+	ChildPolicyOrphan  = "orphan"  // children become top-level tasks
+	ChildPolicyCascade = "cascade" // children are soft-deleted with their parent
+)
+
+var errTaskCycle = errors.New("a task cannot be moved under itself or one of its subtasks")
+
+// checkNoCycle walks up from parentID and fails if it reaches taskID.
+func checkNoCycle(db *gorm.DB, taskID uint, parentID uint) error {
+	current := &parentID
+	for depth := 0; current != nil; depth++ {
+		if *current == taskID || depth > maxTaskDepth {
+			return errTaskCycle
+		}
+		var ancestor models.Task
+		if err := db.Select("id", "parent_id").First(&ancestor, *current).Error; err != nil {
+			return err
+		}
+		current = ancestor.ParentID
+	}
+	return nil
+}
+
+// nextChildPosition returns the position that appends a task after the
+// existing children of parentID (or after the top-level tasks when nil).
+func nextChildPosition(db *gorm.DB, userID uint, parentID *uint) (int, error) {
+	var max *int
+	query := db.Model(&models.Task{})
+	if parentID == nil {
+		query = query.Where("user_id = ? AND parent_id IS NULL", userID)
+	} else {
+		query = query.Where("parent_id = ?", *parentID)
+	}
+	if err := query.Select("MAX(position)").Scan(&max).Error; err != nil {
+		return 0, err
+	}
+	if max == nil {
+		return 0, nil
+	}
+	return *max + 1, nil
+}
+
+// loadDescendants returns every live task below the given roots, one
+// level per query.
+func loadDescendants(db *gorm.DB, rootIDs []uint) ([]models.Task, error) {
+	var all []models.Task
+	frontier := rootIDs
+	for depth := 0; len(frontier) > 0 && depth <= maxTaskDepth; depth++ {
+		var level []models.Task
+		if err := db.Where("parent_id IN ?", frontier).Order("position, id").Find(&level).Error; err != nil {
+			return nil, err
+		}
+		frontier = frontier[:0:0]
+		for _, t := range level {
+			frontier = append(frontier, t.ID)
+		}
+		all = append(all, level...)
+	}
+	return all, nil
+}
+
+// fillProgress sets Progress on each of tasks from its subtree. A leaf is
+// done or not; a parent is the mean of its children's progress.
+func fillProgress(db *gorm.DB, tasks []models.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(tasks))
+	for i, t := range tasks {
+		ids[i] = t.ID
+	}
+	descendants, err := loadDescendants(db, ids)
+	if err != nil {
+		return err
+	}
+
+	children := map[uint][]models.Task{}
+	for _, d := range descendants {
+		children[*d.ParentID] = append(children[*d.ParentID], d)
+	}
+
+	var progress func(t models.Task) float64
+	progress = func(t models.Task) float64 {
+		kids := children[t.ID]
+		if len(kids) == 0 {
+			if t.Status == models.StatusCompleted {
+				return 1
+			}
+			return 0
+		}
+		var sum float64
+		for _, k := range kids {
+			sum += progress(k)
+		}
+		return sum / float64(len(kids))
+	}
+
+	for i := range tasks {
+		p := progress(tasks[i])
+		tasks[i].Progress = &p
+	}
+	return nil
+}
+
+func GetSubtasks(c *gin.Context) {
+	parent, err := findTask(c, config.DB, c.Param("id"), models.WorkspaceReadRoles)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+
+	var children []models.Task
+	if err := config.DB.Where("parent_id = ?", parent.ID).Order("position, id").Find(&children).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subtasks"})
+		return
+	}
+
+	if err := fillProgress(config.DB, children); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute progress"})
+		return
+	}
+
+	c.JSON(http.StatusOK, children)
+}
+
+type ReparentTaskInput struct {
+	ParentID *uint `json:"parent_id"` // null moves the task to the top level
+}
+
+func ReparentTask(c *gin.Context) {
+	var input ReparentTaskInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, ok := taskForWrite(c, config.DB, c.Param("id"))
+	if !ok {
+		return
+	}
+	if !checkIfMatch(c, config.DB, task) {
+		return
+	}
+
+	tx := config.DB.Begin()
+	if err := lockUnchanged(c, tx, task); err != nil {
+		tx.Rollback()
+		respondWriteConflict(c, err, "Failed to move task")
+		return
+	}
+
+	if input.ParentID != nil {
+		parent, err := findUserTask(c, tx, *input.ParentID)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parent task not found"})
+			return
+		}
+		if !sameID(parent.WorkspaceID, task.WorkspaceID) {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Subtasks must be in the same workspace as their parent"})
+			return
+		}
+		if err := checkNoCycle(tx, task.ID, *input.ParentID); err != nil {
+			tx.Rollback()
+			if errors.Is(err, errTaskCycle) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check task hierarchy"})
+			return
+		}
+	}
+
+	position, err := nextChildPosition(tx, task.UserID, input.ParentID)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move task"})
+		return
+	}
+
+	before := history.Snapshot(task)
+	task.ParentID = input.ParentID
+	task.Position = position
+	if err := tx.Save(&task).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move task"})
+		return
+	}
+	userID, _ := c.Get("user_id")
+	if err := history.Record(tx, task.ID, userID.(uint), models.TaskEventUpdated, history.Diff(&before, history.Snapshot(task))); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move task"})
+		return
+	}
+
+	tx.Commit()
+
+	respondTask(c, http.StatusOK, task.ID)
+}
+
+type ReorderSubtasksInput struct {
+	Order []uint `json:"order" binding:"required"` // Child IDs in their new order
+}
+
+func ReorderSubtasks(c *gin.Context) {
+	var input ReorderSubtasksInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	parent, ok := taskForWrite(c, config.DB, c.Param("id"))
+	if !ok {
+		return
+	}
+	if !checkIfMatch(c, config.DB, parent) {
+		return
+	}
+
+	tx := config.DB.Begin()
+	if err := lockUnchanged(c, tx, parent); err != nil {
+		tx.Rollback()
+		respondWriteConflict(c, err, "Failed to reorder subtasks")
+		return
+	}
+
+	var children []models.Task
+	if err := tx.Where("parent_id = ?", parent.ID).Find(&children).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subtasks"})
+		return
+	}
+
+	byID := map[uint]bool{}
+	for _, child := range children {
+		byID[child.ID] = true
+	}
+	if len(input.Order) != len(children) {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Order must list every subtask exactly once"})
+		return
+	}
+	for _, id := range input.Order {
+		if !byID[id] {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Order must list every subtask exactly once"})
+			return
+		}
+		delete(byID, id)
+	}
+
+	for position, id := range input.Order {
+		if err := tx.Model(&models.Task{}).Where("id = ?", id).Update("position", position).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder subtasks"})
+			return
+		}
+	}
+
+	tx.Commit()
+
+	var ordered []models.Task
+	config.DB.Where("parent_id = ?", parent.ID).Order("position, id").Find(&ordered)
+	c.JSON(http.StatusOK, ordered)
+}
+
+// deleteTaskTree moves task to the trash on behalf of actorID and applies
+// policy to its subtasks. Everything it trashes, including the task's
+// comments, shares one deleted_at so RestoreTask can bring it back
+// together.
+func deleteTaskTree(tx *gorm.DB, task models.Task, policy string, actorID uint) error {
+	deleted := []uint{task.ID}
+	switch policy {
+	case ChildPolicyCascade:
+		descendants, err := loadDescendants(tx, []uint{task.ID})
+		if err != nil {
+			return err
+		}
+		for _, d := range descendants {
+			deleted = append(deleted, d.ID)
+		}
+	default:
+		if err := unlinkTasks(tx, "parent_id", task.ID, actorID); err != nil {
+			return err
+		}
+	}
+
+	for _, id := range deleted {
+		if err := history.Record(tx, id, actorID, models.TaskEventDeleted, nil); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	if err := tx.Model(&models.Comment{}).Where("task_id IN ?", deleted).Update("deleted_at", now).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.Task{}).Where("id IN ?", deleted).Update("deleted_at", now).Error
+}
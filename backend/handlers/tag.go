@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"taskmanager-backend/backend/config"
+	"taskmanager-backend/backend/history"
+	"taskmanager-backend/backend/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type TagInput struct {
+	Name  string `json:"name" binding:"required"`
+	Color string `json:"color"`
+}
+
+func GetTags(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var tags []models.Tag
+	if err := config.DB.Where("user_id = ?", userID).Order("name").Find(&tags).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tags"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
+func CreateTag(c *gin.Context) {
+	var input TagInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	tag := models.Tag{
+		UserID: userID.(uint),
+		Name:   strings.TrimSpace(input.Name),
+		Color:  input.Color,
+	}
+	if tag.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tag name is required"})
+		return
+	}
+
+	if err := config.DB.Create(&tag).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Tag already exists"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, tag)
+}
+
+func UpdateTag(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var tag models.Tag
+	if err := config.DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&tag).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	var input TagInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tag.Name = strings.TrimSpace(input.Name)
+	tag.Color = input.Color
+	if tag.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tag name is required"})
+		return
+	}
+
+	if err := config.DB.Save(&tag).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Tag already exists"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tag)
+}
+
+func DeleteTag(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var tag models.Tag
+	if err := config.DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&tag).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	tx := config.DB.Begin()
+	if err := tx.Exec("DELETE FROM task_tags WHERE tag_id = ?", tag.ID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete tag"})
+		return
+	}
+	if err := tx.Delete(&tag).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete tag"})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tag deleted successfully"})
+}
+
+type AttachTagsInput struct {
+	TagIDs []uint `json:"tag_ids" binding:"required"`
+}
+
+func AttachTaskTags(c *gin.Context) {
+	var input AttachTagsInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, ok := taskForWrite(c, config.DB, c.Param("id"))
+	if !ok {
+		return
+	}
+	if !checkIfMatch(c, config.DB, task) {
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	var tags []models.Tag
+	if err := config.DB.Where("id IN ? AND user_id = ?", input.TagIDs, userID).Find(&tags).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tags"})
+		return
+	}
+	if len(tags) != len(uniqueIDs(input.TagIDs)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	retagTask(c, task, "Failed to attach tags", func(tx *gorm.DB) error {
+		return tx.Model(&task).Association("Tags").Append(tags)
+	})
+}
+
+func DetachTaskTag(c *gin.Context) {
+	task, ok := taskForWrite(c, config.DB, c.Param("id"))
+	if !ok {
+		return
+	}
+	if !checkIfMatch(c, config.DB, task) {
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	var tag models.Tag
+	if err := config.DB.Where("id = ? AND user_id = ?", c.Param("tag_id"), userID).First(&tag).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	retagTask(c, task, "Failed to detach tag", func(tx *gorm.DB) error {
+		return tx.Model(&task).Association("Tags").Delete(&tag)
+	})
+}
+
+// retagTask applies change to the task's tags in one transaction. If the
+// tags end up different, the task is touched so its version moves and the
+// old and new tag ids are recorded in its history.
+func retagTask(c *gin.Context, task models.Task, failure string, change func(tx *gorm.DB) error) {
+	tx := config.DB.Begin()
+	if err := lockUnchanged(c, tx, task); err != nil {
+		tx.Rollback()
+		respondWriteConflict(c, err, failure)
+		return
+	}
+
+	before, err := taskTagIDs(tx, task.ID)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": failure})
+		return
+	}
+	if err := change(tx); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": failure})
+		return
+	}
+	after, err := taskTagIDs(tx, task.ID)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": failure})
+		return
+	}
+
+	from, _ := json.Marshal(before)
+	to, _ := json.Marshal(after)
+	if !bytes.Equal(from, to) {
+		userID, _ := c.Get("user_id")
+		changes := map[string]models.FieldChange{"tags": {From: from, To: to}}
+		if err := tx.Model(&models.Task{}).Where("id = ?", task.ID).Update("updated_at", time.Now()).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": failure})
+			return
+		}
+		if err := history.Record(tx, task.ID, userID.(uint), models.TaskEventUpdated, changes); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": failure})
+			return
+		}
+	}
+	tx.Commit()
+
+	respondTask(c, http.StatusOK, task.ID)
+}
+
+// taskTagIDs lists the ids of the tags on a task in ascending order.
+func taskTagIDs(db *gorm.DB, taskID uint) ([]uint, error) {
+	ids := []uint{}
+	err := db.Table("task_tags").Where("task_id = ?", taskID).Order("tag_id").Pluck("tag_id", &ids).Error
+	return ids, err
+}
+
+func uniqueIDs(ids []uint) map[uint]bool {
+	set := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
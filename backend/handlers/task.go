@@ -1,12 +1,21 @@
 package handlers
 
 import (
+	"errors"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"taskmanager-backend/backend/config"
+	"taskmanager-backend/backend/history"
+	"taskmanager-backend/backend/ledger"
 	"taskmanager-backend/backend/models"
+	"taskmanager-backend/backend/recurrence"
+	"taskmanager-backend/backend/workflow"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type CreateTaskInput struct {
@@ -16,6 +25,24 @@ type CreateTaskInput struct {
 	Priority    string `json:"priority"`
 	DueDate     string `json:"due_date"`
 	Assignee    string `json:"assignee"`
+	WorkspaceID *uint  `json:"workspace_id"`
+	ProjectID   *uint  `json:"project_id"`
+	ParentID    *uint  `json:"parent_id"`
+	// iCalendar RRULE, e.g. "FREQ=WEEKLY;BYDAY=MO,TH;COUNT=10". The due date
+	// is the first occurrence.
+	RecurrenceRule string `json:"recurrence_rule"`
+}
+
+// UpdateTaskInput replaces every editable field of a task (PUT). Fields
+// left out are cleared; PATCH merges into the current values instead.
+type UpdateTaskInput struct {
+	Title       string `json:"title" binding:"required"`
+	Description string `json:"description"`
+	Status      string `json:"status" binding:"required"`
+	Priority    string `json:"priority" binding:"required"`
+	DueDate     string `json:"due_date"`
+	Assignee    string `json:"assignee"`
+	ProjectID   *uint  `json:"project_id"`
 }
 
 func parseDate(dateStr string) (*time.Time, error) {
@@ -35,6 +62,51 @@ func parseDate(dateStr string) (*time.Time, error) {
 	return nil, err
 }
 
+// taskError is a failed task operation and the response it maps to. The
+// single-task handlers write it directly; the bulk endpoint collects them.
+type taskError struct {
+	Status int
+	Body   gin.H
+}
+
+func newTaskError(status int, message string) *taskError {
+	return &taskError{Status: status, Body: gin.H{"error": message}}
+}
+
+func (e *taskError) Error() string {
+	msg, _ := e.Body["error"].(string)
+	return msg
+}
+
+func (e *taskError) respond(c *gin.Context) {
+	c.JSON(e.Status, e.Body)
+}
+
+// priorityError rejects priorities outside models.TaskPriorities with 422.
+func priorityError(priority string) *taskError {
+	if !workflow.ValidPriority(priority) {
+		return &taskError{Status: http.StatusUnprocessableEntity, Body: gin.H{"error": "Invalid priority " + strconv.Quote(priority), "allowed": models.TaskPriorities}}
+	}
+	return nil
+}
+
+// transitionError maps an illegal status change to 422.
+func transitionError(err error) *taskError {
+	var te *workflow.TransitionError
+	if errors.As(err, &te) {
+		if te.From == "" {
+			return &taskError{Status: http.StatusUnprocessableEntity, Body: gin.H{"error": te.Error(), "allowed": models.TaskStatuses}}
+		}
+		return &taskError{Status: http.StatusUnprocessableEntity, Body: gin.H{"error": te.Error(), "allowed": te.Allowed}}
+	}
+	return newTaskError(http.StatusInternalServerError, "Failed to update status")
+}
+
+// respondTransitionError reports an illegal status change with 422.
+func respondTransitionError(c *gin.Context, err error) {
+	transitionError(err).respond(c)
+}
+
 func CreateTask(c *gin.Context) {
 	var input CreateTaskInput
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -42,27 +114,62 @@ func CreateTask(c *gin.Context) {
 		return
 	}
 
+	// Start DB transaction
+	tx := config.DB.Begin()
+	task, terr := createTaskTx(c, tx, input)
+	if terr != nil {
+		tx.Rollback()
+		terr.respond(c)
+		return
+	}
+	tx.Commit()
+
+	respondTask(c, http.StatusCreated, task.ID)
+}
+
+// createTaskTx validates input and creates the task in tx, charging the
+// caller one credit.
+func createTaskTx(c *gin.Context, tx *gorm.DB, input CreateTaskInput) (models.Task, *taskError) {
 	dueDate, err := parseDate(input.DueDate)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Expected YYYY-MM-DD or RFC3339"})
-		return
+		return models.Task{}, newTaskError(http.StatusBadRequest, "Invalid date format. Expected YYYY-MM-DD or RFC3339")
 	}
 
 	task := models.Task{
 		Title:       input.Title,
 		Description: input.Description,
-		Status:      input.Status,
 		Priority:    input.Priority,
 		DueDate:     dueDate,
 		Assignee:    input.Assignee,
 	}
 
+	if input.RecurrenceRule != "" {
+		rule, err := recurrence.Parse(input.RecurrenceRule)
+		if err != nil {
+			return task, newTaskError(http.StatusBadRequest, "Invalid recurrence rule: "+err.Error())
+		}
+		if dueDate == nil {
+			return task, newTaskError(http.StatusBadRequest, "Recurring tasks need a due_date for their first occurrence")
+		}
+		task.RecurrenceRule = rule.String()
+		task.RecurrenceStart = dueDate
+		task.RecurrenceIndex = 1
+	}
+
 	// Set defaults if empty
-	if task.Status == "" {
-		task.Status = "pending"
+	status := input.Status
+	if status == "" {
+		status = models.StatusPending
 	}
 	if task.Priority == "" {
-		task.Priority = "medium"
+		task.Priority = models.PriorityMedium
+	}
+
+	if err := workflow.Tasks.Start(&task, status, time.Now()); err != nil {
+		return task, transitionError(err)
+	}
+	if e := priorityError(task.Priority); e != nil {
+		return task, e
 	}
 
 	// Assign user ID
@@ -71,153 +178,272 @@ func CreateTask(c *gin.Context) {
 		task.UserID = userID.(uint)
 	}
 
-	// Start DB transaction
-	tx := config.DB.Begin()
+	// Place in workspace
+	if input.WorkspaceID != nil {
+		if !hasRole(workspaceRole(c, tx, *input.WorkspaceID), models.WorkspaceWriteRoles) {
+			return task, newTaskError(http.StatusForbidden, "You can't add tasks to this workspace")
+		}
+		task.WorkspaceID = input.WorkspaceID
+	}
 
-	// Check User Credits
-	var user models.User
-	if err := tx.First(&user, task.UserID).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
-		return
+	// Attach to project
+	if input.ProjectID != nil {
+		if _, err := findUserProject(c, tx, *input.ProjectID); err != nil {
+			return task, newTaskError(http.StatusBadRequest, "Project not found")
+		}
+		task.ProjectID = input.ProjectID
 	}
 
-	if user.Credits < 1 {
-		tx.Rollback()
-		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient credits"})
-		return
+	// Attach to parent task
+	if input.ParentID != nil {
+		parent, err := findUserTask(c, tx, *input.ParentID)
+		if err != nil {
+			return task, newTaskError(http.StatusBadRequest, "Parent task not found")
+		}
+		if !sameID(parent.WorkspaceID, task.WorkspaceID) {
+			return task, newTaskError(http.StatusBadRequest, "Subtasks must be in the same workspace as their parent")
+		}
+		task.ParentID = input.ParentID
+	}
+	position, err := nextChildPosition(tx, task.UserID, task.ParentID)
+	if err != nil {
+		return task, newTaskError(http.StatusInternalServerError, "Failed to create task")
+	}
+	task.Position = position
+
+	if e := unverifiedTaskLimit(tx, task.UserID); e != nil {
+		return task, e
 	}
 
 	// Deduct Credit
-	user.Credits -= 1
-	if err := tx.Save(&user).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update credits"})
-		return
+	if _, err := ledger.Debit(tx, task.UserID, 1, "usage", "Created task: "+task.Title); err != nil {
+		switch {
+		case errors.Is(err, ledger.ErrInsufficientCredits):
+			return task, newTaskError(http.StatusForbidden, "Insufficient credits")
+		case errors.Is(err, ledger.ErrCreditHold):
+			return task, newTaskError(http.StatusForbidden, "Credits are on hold until the outstanding balance is repaid")
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return task, newTaskError(http.StatusInternalServerError, "Failed to fetch user")
+		default:
+			return task, newTaskError(http.StatusInternalServerError, "Failed to update credits")
+		}
 	}
 
 	// Create Task
 	if err := tx.Create(&task).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create task"})
-		return
+		return task, newTaskError(http.StatusInternalServerError, "Failed to create task")
 	}
-
-	// Log Transaction
-	transaction := models.Transaction{
-		UserID:      user.ID,
-		Amount:      -1,
-		Type:        "usage",
-		Description: "Created task: " + task.Title,
+	if err := history.Record(tx, task.ID, task.UserID, models.TaskEventCreated, history.Diff(nil, history.Snapshot(task))); err != nil {
+		return task, newTaskError(http.StatusInternalServerError, "Failed to create task")
 	}
-	if err := tx.Create(&transaction).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log transaction"})
+
+	return task, nil
+}
+
+func GetTasks(c *gin.Context) {
+	params, err := ParseTaskListQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	tx.Commit()
-
-	c.JSON(http.StatusCreated, task)
+	listTasks(c, params)
 }
 
-func GetTasks(c *gin.Context) {
-	userID, exists := c.Get("user_id")
+// listTasks writes one page of the tasks the caller can see matching params.
+func listTasks(c *gin.Context, params *TaskListQuery) {
 	var tasks []models.Task
 
-	query := config.DB
-	if exists {
-		query = query.Where("user_id = ?", userID)
-	}
+	query := scopeTasks(c, config.DB.Model(&models.Task{}), models.WorkspaceReadRoles)
 
-	if err := query.Find(&tasks).Error; err != nil {
+	if err := params.Apply(query).Preload("Tags").Find(&tasks).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tasks"})
 		return
 	}
 
-	c.JSON(http.StatusOK, tasks)
+	var nextCursor *string
+	if len(tasks) > params.Limit {
+		tasks = tasks[:params.Limit]
+		cursor := params.NextCursor(tasks[len(tasks)-1])
+		nextCursor = &cursor
+	}
+	if tasks == nil {
+		tasks = []models.Task{}
+	}
+
+	respondWithETag(c, http.StatusOK, gin.H{"data": tasks, "next_cursor": nextCursor})
 }
 
 func GetTask(c *gin.Context) {
 	id := c.Param("id")
-	userID, exists := c.Get("user_id")
 
-	var task models.Task
-	query := config.DB.Where("id = ?", id)
-	if exists {
-		query = query.Where("user_id = ?", userID)
+	task, err := findTask(c, config.DB, id, models.WorkspaceReadRoles)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
 	}
 
-	if err := query.First(&task).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+	respondTask(c, http.StatusOK, task.ID)
+}
+
+// respondTask writes the current detail view of a task with its ETag.
+func respondTask(c *gin.Context, status int, id uint) {
+	task, err := loadTaskDetail(config.DB, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch task"})
 		return
 	}
 
-	c.JSON(http.StatusOK, task)
+	respondWithETag(c, status, task)
 }
 
 func UpdateTask(c *gin.Context) {
 	id := c.Param("id")
-	userID, exists := c.Get("user_id")
 
-	var task models.Task
-	query := config.DB.Where("id = ?", id)
-	if exists {
-		query = query.Where("user_id = ?", userID)
+	task, ok := taskForWrite(c, config.DB, id)
+	if !ok {
+		return
 	}
 
-	if err := query.First(&task).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+	if !checkIfMatch(c, config.DB, task) {
 		return
 	}
 
-	var input CreateTaskInput
+	var input UpdateTaskInput
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	saveTaskUpdate(c, task, input)
+}
+
+// saveTaskUpdate validates input, applies it to task and writes the
+// response. PUT and PATCH both end here.
+func saveTaskUpdate(c *gin.Context, task models.Task, input UpdateTaskInput) {
+	tx := config.DB.Begin()
+	if err := lockUnchanged(c, tx, task); err != nil {
+		tx.Rollback()
+		respondWriteConflict(c, err, "Failed to update task")
+		return
+	}
+	task, completing, terr := updateTaskTx(c, tx, task, input)
+	if terr != nil {
+		tx.Rollback()
+		terr.respond(c)
+		return
+	}
+	tx.Commit()
+
+	if completing {
+		spawnNextOccurrence(task)
+	}
+
+	respondTask(c, http.StatusOK, task.ID)
+}
+
+// updateTaskTx validates input and applies it to task in tx. completing
+// reports whether the task was just completed, in which case the caller
+// should spawnNextOccurrence once tx commits.
+func updateTaskTx(c *gin.Context, tx *gorm.DB, task models.Task, input UpdateTaskInput) (updated models.Task, completing bool, terr *taskError) {
+	input.Title = strings.TrimSpace(input.Title)
+	if input.Title == "" {
+		return task, false, newTaskError(http.StatusBadRequest, "Title is required")
+	}
+
 	dueDate, err := parseDate(input.DueDate)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Expected YYYY-MM-DD or RFC3339"})
-		return
+		return task, false, newTaskError(http.StatusBadRequest, "Invalid date format. Expected YYYY-MM-DD or RFC3339")
+	}
+
+	if e := priorityError(input.Priority); e != nil {
+		return task, false, e
+	}
+
+	if input.ProjectID != nil && !sameID(input.ProjectID, task.ProjectID) {
+		if _, err := findUserProject(c, tx, *input.ProjectID); err != nil {
+			return task, false, newTaskError(http.StatusBadRequest, "Project not found")
+		}
+	}
+
+	before := history.Snapshot(task)
+	completing = input.Status == models.StatusCompleted && task.Status != models.StatusCompleted
+
+	// A task can't be completed while anything blocking it is still open
+	if completing {
+		if e := blockersError(tx, task.ID); e != nil {
+			return task, false, e
+		}
+	}
+
+	if err := workflow.Tasks.Transition(&task, input.Status, time.Now()); err != nil {
+		return task, false, transitionError(err)
 	}
 
 	// Update fields
 	task.Title = input.Title
 	task.Description = input.Description
-	task.Status = input.Status
 	task.Priority = input.Priority
 	task.DueDate = dueDate
 	task.Assignee = input.Assignee
+	task.ProjectID = input.ProjectID
 
-	if err := config.DB.Save(&task).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task"})
-		return
+	if err := tx.Save(&task).Error; err != nil {
+		return task, false, newTaskError(http.StatusInternalServerError, "Failed to update task")
 	}
+	userID, _ := c.Get("user_id")
+	if err := history.Record(tx, task.ID, userID.(uint), models.TaskEventUpdated, history.Diff(&before, history.Snapshot(task))); err != nil {
+		return task, false, newTaskError(http.StatusInternalServerError, "Failed to update task")
+	}
+
+	return task, completing, nil
+}
 
-	c.JSON(http.StatusOK, task)
+// spawnNextOccurrence generates the next occurrence of a recurring task
+// right after it is completed and returns its id, or nil if none was
+// generated. Failures are left for the scheduler to retry.
+func spawnNextOccurrence(task models.Task) *uint {
+	next, err := recurrence.SpawnNext(config.DB, task, time.Now())
+	if err != nil {
+		log.Printf("Failed to generate next occurrence of task %d: %v", task.ID, err)
+		return nil
+	}
+	if next == nil {
+		return nil
+	}
+	return &next.ID
 }
 
 func DeleteTask(c *gin.Context) {
 	id := c.Param("id")
-	userID, exists := c.Get("user_id")
 
-	var task models.Task
-	query := config.DB.Where("id = ?", id)
-	if exists {
-		query = query.Where("user_id = ?", userID)
+	policy := c.DefaultQuery("children", ChildPolicyOrphan)
+	if policy != ChildPolicyOrphan && policy != ChildPolicyCascade {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "children must be 'orphan' or 'cascade'"})
+		return
 	}
 
-	if err := query.First(&task).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+	task, ok := taskForWrite(c, config.DB, id)
+	if !ok {
+		return
+	}
+	if !checkIfMatch(c, config.DB, task) {
 		return
 	}
 
-	if err := config.DB.Delete(&task).Error; err != nil {
+	userID, _ := c.Get("user_id")
+	tx := config.DB.Begin()
+	if err := lockUnchanged(c, tx, task); err != nil {
+		tx.Rollback()
+		respondWriteConflict(c, err, "Failed to delete task")
+		return
+	}
+	if err := deleteTaskTree(tx, task, policy, userID.(uint)); err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete task"})
 		return
 	}
+	tx.Commit()
 
 	c.JSON(http.StatusOK, gin.H{"message": "Task deleted successfully"})
 }
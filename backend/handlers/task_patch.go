@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"taskmanager-backend/backend/config"
+	"taskmanager-backend/backend/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// PatchTask applies a JSON Merge Patch (RFC 7396) to a task: fields that
+// are left out keep their value and null clears a field. The merged task
+// goes through the same validation as PUT.
+func PatchTask(c *gin.Context) {
+	if mediaType, _, _ := mime.ParseMediaType(c.ContentType()); mediaType != "application/merge-patch+json" && mediaType != "application/json" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Use Content-Type application/merge-patch+json"})
+		return
+	}
+
+	task, ok := taskForWrite(c, config.DB, c.Param("id"))
+	if !ok {
+		return
+	}
+	if !checkIfMatch(c, config.DB, task) {
+		return
+	}
+
+	raw, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+	input, terr := mergeTaskPatch(task, raw)
+	if terr != nil {
+		terr.respond(c)
+		return
+	}
+
+	saveTaskUpdate(c, task, input)
+}
+
+// mergeTaskPatch applies a merge patch document to task's editable fields
+// and validates the result like a PUT body.
+func mergeTaskPatch(task models.Task, raw []byte) (UpdateTaskInput, *taskError) {
+	var input UpdateTaskInput
+
+	var patch interface{}
+	if err := json.Unmarshal(raw, &patch); err != nil {
+		return input, newTaskError(http.StatusBadRequest, "Invalid JSON: "+err.Error())
+	}
+	if _, isObject := patch.(map[string]interface{}); !isObject {
+		return input, newTaskError(http.StatusBadRequest, "Patch must be a JSON object")
+	}
+
+	current := UpdateTaskInput{
+		Title:       task.Title,
+		Description: task.Description,
+		Status:      task.Status,
+		Priority:    task.Priority,
+		Assignee:    task.Assignee,
+		ProjectID:   task.ProjectID,
+	}
+	if task.DueDate != nil {
+		current.DueDate = task.DueDate.Format(time.RFC3339)
+	}
+	var doc interface{}
+	encoded, _ := json.Marshal(current)
+	json.Unmarshal(encoded, &doc)
+
+	merged, _ := json.Marshal(mergePatch(doc, patch))
+	dec := json.NewDecoder(bytes.NewReader(merged))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&input); err != nil {
+		return input, newTaskError(http.StatusBadRequest, err.Error())
+	}
+	if err := binding.Validator.ValidateStruct(&input); err != nil {
+		return input, newTaskError(http.StatusBadRequest, err.Error())
+	}
+	return input, nil
+}
+
+// mergePatch implements the MergePatch algorithm from RFC 7396 over
+// generic JSON values.
+func mergePatch(target, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = map[string]interface{}{}
+	}
+	for name, value := range patchObj {
+		if value == nil {
+			delete(targetObj, name)
+			continue
+		}
+		targetObj[name] = mergePatch(targetObj[name], value)
+	}
+	return targetObj
+}
@@ -0,0 +1,500 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"taskmanager-backend/backend/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultTaskPageSize = 50
+	maxTaskPageSize     = 200
+)
+
+type sortKind int
+
+const (
+	sortString sortKind = iota
+	sortInt
+	sortTime
+)
+
+// taskSortField describes a column of models.Task that clients may sort on.
+// Only fields listed in taskSortFields ever reach the ORDER BY clause.
+type taskSortField struct {
+	expr     string
+	kind     sortKind
+	nullable bool
+	value    func(t models.Task) interface{}
+}
+
+var taskSortFields = map[string]taskSortField{
+	"id":         {expr: "tasks.id", kind: sortInt, value: func(t models.Task) interface{} { return int64(t.ID) }},
+	"title":      {expr: "tasks.title", kind: sortString, value: func(t models.Task) interface{} { return t.Title }},
+	"status":     {expr: "tasks.status", kind: sortString, value: func(t models.Task) interface{} { return t.Status }},
+	"position":   {expr: "tasks.position", kind: sortInt, value: func(t models.Task) interface{} { return int64(t.Position) }},
+	"assignee":   {expr: "tasks.assignee", kind: sortString, value: func(t models.Task) interface{} { return t.Assignee }},
+	"created_at": {expr: "tasks.created_at", kind: sortTime, value: func(t models.Task) interface{} { return t.CreatedAt }},
+	"updated_at": {expr: "tasks.updated_at", kind: sortTime, value: func(t models.Task) interface{} { return t.UpdatedAt }},
+	"due_date": {expr: "tasks.due_date", kind: sortTime, nullable: true, value: func(t models.Task) interface{} {
+		if t.DueDate == nil {
+			return nil
+		}
+		return *t.DueDate
+	}},
+	// Priority sorts by rank rather than alphabetically.
+	"priority": {
+		expr: "CASE tasks.priority WHEN 'low' THEN 1 WHEN 'medium' THEN 2 WHEN 'high' THEN 3 ELSE 0 END",
+		kind: sortInt,
+		value: func(t models.Task) interface{} {
+			switch t.Priority {
+			case "low":
+				return int64(1)
+			case "medium":
+				return int64(2)
+			case "high":
+				return int64(3)
+			}
+			return int64(0)
+		},
+	},
+}
+
+type taskSortKey struct {
+	name  string
+	field taskSortField
+	desc  bool
+}
+
+// TaskListQuery holds the validated filters, sort order and page position
+// for GET /api/tasks.
+type TaskListQuery struct {
+	Statuses      []string
+	Priorities    []string
+	Assignees     []string
+	DueAfter      *time.Time
+	DueBefore     *time.Time
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+	ParentID      *uint
+	TopLevelOnly  bool
+	WorkspaceID   *uint
+	PersonalOnly  bool
+	ProjectID     *uint
+	NoProject     bool
+	// Tasks in archived projects are hidden unless asked for or unless a
+	// single project is being listed. Only the archives of UserID, the
+	// caller, count: projects are personal, and another member archiving
+	// theirs shouldn't hide a shared task from everyone else.
+	IncludeArchived bool
+	UserID          interface{}
+	Tags            []string // tag names
+	MatchAllTags    bool     // AND the tags together instead of OR
+	Limit           int
+
+	sortSpec string
+	sort     []taskSortKey
+	cursor   *taskCursor
+}
+
+type taskCursor struct {
+	Sort   string        `json:"s"`
+	Values []interface{} `json:"v"`
+}
+
+func uniqueStrings(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+func splitList(raw string) []string {
+	var out []string
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// ParseTaskListQuery reads the task listing query parameters and rejects
+// anything that does not map onto a known models.Task column.
+func ParseTaskListQuery(c *gin.Context) (*TaskListQuery, error) {
+	q := &TaskListQuery{
+		Statuses:   splitList(c.Query("status")),
+		Priorities: splitList(c.Query("priority")),
+		Assignees:  splitList(c.Query("assignee")),
+		Tags:       splitList(c.Query("tags")),
+		Limit:      defaultTaskPageSize,
+	}
+
+	dates := []struct {
+		param string
+		dst   **time.Time
+	}{
+		{"due_after", &q.DueAfter},
+		{"due_before", &q.DueBefore},
+		{"created_after", &q.CreatedAfter},
+		{"created_before", &q.CreatedBefore},
+		{"updated_after", &q.UpdatedAfter},
+		{"updated_before", &q.UpdatedBefore},
+	}
+	for _, d := range dates {
+		parsed, err := parseDate(c.Query(d.param))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: expected YYYY-MM-DD or RFC3339", d.param)
+		}
+		*d.dst = parsed
+	}
+
+	switch c.DefaultQuery("tag_match", "any") {
+	case "any":
+	case "all":
+		q.MatchAllTags = true
+	default:
+		return nil, errors.New("tag_match must be 'any' or 'all'")
+	}
+
+	switch raw := c.Query("workspace_id"); raw {
+	case "":
+	case "none":
+		q.PersonalOnly = true
+	default:
+		workspaceID, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return nil, errors.New("workspace_id must be a workspace id or 'none'")
+		}
+		id := uint(workspaceID)
+		q.WorkspaceID = &id
+	}
+
+	switch raw := c.Query("project_id"); raw {
+	case "":
+	case "none":
+		q.NoProject = true
+	default:
+		projectID, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return nil, errors.New("project_id must be a project id or 'none'")
+		}
+		id := uint(projectID)
+		q.ProjectID = &id
+	}
+	q.IncludeArchived = c.Query("include_archived") == "true"
+	q.UserID, _ = c.Get("user_id")
+
+	switch raw := c.Query("parent_id"); raw {
+	case "":
+	case "none":
+		q.TopLevelOnly = true
+	default:
+		parentID, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return nil, errors.New("parent_id must be a task id or 'none'")
+		}
+		id := uint(parentID)
+		q.ParentID = &id
+	}
+
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			return nil, errors.New("limit must be a positive integer")
+		}
+		if limit > maxTaskPageSize {
+			limit = maxTaskPageSize
+		}
+		q.Limit = limit
+	}
+
+	if err := q.parseSort(c.DefaultQuery("sort", "id")); err != nil {
+		return nil, err
+	}
+
+	if raw := c.Query("cursor"); raw != "" {
+		cursor, err := q.decodeCursor(raw)
+		if err != nil {
+			return nil, err
+		}
+		q.cursor = cursor
+	}
+
+	return q, nil
+}
+
+func (q *TaskListQuery) parseSort(raw string) error {
+	seen := map[string]bool{}
+	var names []string
+	for _, part := range splitList(raw) {
+		desc := strings.HasPrefix(part, "-")
+		name := strings.TrimPrefix(part, "-")
+		field, ok := taskSortFields[name]
+		if !ok {
+			return fmt.Errorf("cannot sort by %q", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("duplicate sort field %q", name)
+		}
+		seen[name] = true
+		q.sort = append(q.sort, taskSortKey{name: name, field: field, desc: desc})
+		names = append(names, part)
+	}
+
+	// The id column breaks ties so that every row has a unique position.
+	if !seen["id"] {
+		q.sort = append(q.sort, taskSortKey{name: "id", field: taskSortFields["id"]})
+		names = append(names, "id")
+	}
+	q.sortSpec = strings.Join(names, ",")
+	return nil
+}
+
+// Apply adds the filters, ordering, cursor condition and limit to query.
+// One extra row is requested so the caller can tell if another page exists.
+func (q *TaskListQuery) Apply(query *gorm.DB) *gorm.DB {
+	if len(q.Statuses) > 0 {
+		query = query.Where("tasks.status IN ?", q.Statuses)
+	}
+	if len(q.Priorities) > 0 {
+		query = query.Where("tasks.priority IN ?", q.Priorities)
+	}
+	if len(q.Assignees) > 0 {
+		query = query.Where("tasks.assignee IN ?", q.Assignees)
+	}
+	if q.DueAfter != nil {
+		query = query.Where("tasks.due_date >= ?", *q.DueAfter)
+	}
+	if q.DueBefore != nil {
+		query = query.Where("tasks.due_date <= ?", *q.DueBefore)
+	}
+	if q.CreatedAfter != nil {
+		query = query.Where("tasks.created_at >= ?", *q.CreatedAfter)
+	}
+	if q.CreatedBefore != nil {
+		query = query.Where("tasks.created_at <= ?", *q.CreatedBefore)
+	}
+	if q.UpdatedAfter != nil {
+		query = query.Where("tasks.updated_at >= ?", *q.UpdatedAfter)
+	}
+	if q.UpdatedBefore != nil {
+		query = query.Where("tasks.updated_at <= ?", *q.UpdatedBefore)
+	}
+
+	if q.TopLevelOnly {
+		query = query.Where("tasks.parent_id IS NULL")
+	}
+	if q.ParentID != nil {
+		query = query.Where("tasks.parent_id = ?", *q.ParentID)
+	}
+
+	if q.PersonalOnly {
+		query = query.Where("tasks.workspace_id IS NULL")
+	}
+	if q.WorkspaceID != nil {
+		query = query.Where("tasks.workspace_id = ?", *q.WorkspaceID)
+	}
+
+	switch {
+	case q.ProjectID != nil:
+		query = query.Where("tasks.project_id = ?", *q.ProjectID)
+	case q.NoProject:
+		query = query.Where("tasks.project_id IS NULL")
+	case !q.IncludeArchived:
+		archived := query.Session(&gorm.Session{NewDB: true}).
+			Model(&models.Project{}).
+			Select("id").
+			Where("archived = ? AND user_id = ?", true, q.UserID)
+		query = query.Where("tasks.project_id IS NULL OR tasks.project_id NOT IN (?)", archived)
+	}
+
+	if len(q.Tags) > 0 {
+		tagged := query.Session(&gorm.Session{NewDB: true}).
+			Table("task_tags").
+			Select("task_tags.task_id").
+			Joins("JOIN tags ON tags.id = task_tags.tag_id").
+			Where("tags.name IN ?", q.Tags).
+			Group("task_tags.task_id")
+		// Members of a workspace each have their own tags, so a shared
+		// task can carry two tags with the same name; count names, not ids.
+		if q.MatchAllTags {
+			tagged = tagged.Having("COUNT(DISTINCT tags.name) = ?", len(uniqueStrings(q.Tags)))
+		}
+		query = query.Where("tasks.id IN (?)", tagged)
+	}
+
+	if q.cursor != nil {
+		cond, args := q.keysetCondition()
+		query = query.Where(cond, args...)
+	}
+
+	for _, key := range q.sort {
+		query = query.Order(orderClause(key))
+	}
+
+	return query.Limit(q.Limit + 1)
+}
+
+// Both Postgres and SQLite accept NULLS FIRST/LAST, so nullable columns are
+// pinned to Postgres' default placement on either driver.
+func orderClause(key taskSortKey) string {
+	clause := key.field.expr
+	if key.desc {
+		clause += " DESC"
+	} else {
+		clause += " ASC"
+	}
+	if key.field.nullable {
+		if key.desc {
+			clause += " NULLS FIRST"
+		} else {
+			clause += " NULLS LAST"
+		}
+	}
+	return clause
+}
+
+// keysetCondition builds "(a > x) OR (a = x AND b > y) OR ..." for the
+// cursor's sort values, flipping the comparison for descending keys and
+// treating NULL as larger than every other value.
+func (q *TaskListQuery) keysetCondition() (string, []interface{}) {
+	var branches []string
+	var args []interface{}
+
+	for i, key := range q.sort {
+		var parts []string
+		var branchArgs []interface{}
+
+		for j := 0; j < i; j++ {
+			eq, eqArgs := equalCondition(q.sort[j], q.cursor.Values[j])
+			parts = append(parts, eq)
+			branchArgs = append(branchArgs, eqArgs...)
+		}
+
+		after, afterArgs := afterCondition(key, q.cursor.Values[i])
+		if after == "" {
+			continue
+		}
+		parts = append(parts, after)
+		branchArgs = append(branchArgs, afterArgs...)
+
+		branches = append(branches, "("+strings.Join(parts, " AND ")+")")
+		args = append(args, branchArgs...)
+	}
+
+	if len(branches) == 0 {
+		return "1 = 0", nil
+	}
+	return "(" + strings.Join(branches, " OR ") + ")", args
+}
+
+func equalCondition(key taskSortKey, value interface{}) (string, []interface{}) {
+	if value == nil {
+		return key.field.expr + " IS NULL", nil
+	}
+	return key.field.expr + " = ?", []interface{}{value}
+}
+
+func afterCondition(key taskSortKey, value interface{}) (string, []interface{}) {
+	expr := key.field.expr
+	switch {
+	case value == nil && key.desc:
+		return expr + " IS NOT NULL", nil
+	case value == nil:
+		return "", nil
+	case key.desc:
+		return expr + " < ?", []interface{}{value}
+	case key.field.nullable:
+		return "(" + expr + " > ? OR " + expr + " IS NULL)", []interface{}{value}
+	default:
+		return expr + " > ?", []interface{}{value}
+	}
+}
+
+// NextCursor returns the opaque cursor pointing after last.
+func (q *TaskListQuery) NextCursor(last models.Task) string {
+	cursor := taskCursor{Sort: q.sortSpec}
+	for _, key := range q.sort {
+		v := key.field.value(last)
+		if t, ok := v.(time.Time); ok {
+			v = t.UTC().Format(time.RFC3339Nano)
+		}
+		cursor.Values = append(cursor.Values, v)
+	}
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func (q *TaskListQuery) decodeCursor(raw string) (*taskCursor, error) {
+	invalid := errors.New("invalid cursor")
+
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, invalid
+	}
+
+	var cursor taskCursor
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.UseNumber()
+	if err := dec.Decode(&cursor); err != nil {
+		return nil, invalid
+	}
+	if cursor.Sort != q.sortSpec {
+		return nil, errors.New("cursor does not match the requested sort")
+	}
+	if len(cursor.Values) != len(q.sort) {
+		return nil, invalid
+	}
+
+	for i, key := range q.sort {
+		v := cursor.Values[i]
+		if v == nil {
+			if !key.field.nullable {
+				return nil, invalid
+			}
+			continue
+		}
+		switch key.field.kind {
+		case sortString:
+			s, ok := v.(string)
+			if !ok {
+				return nil, invalid
+			}
+			cursor.Values[i] = s
+		case sortInt:
+			n, ok := v.(json.Number)
+			if !ok {
+				return nil, invalid
+			}
+			parsed, err := n.Int64()
+			if err != nil {
+				return nil, invalid
+			}
+			cursor.Values[i] = parsed
+		case sortTime:
+			s, ok := v.(string)
+			if !ok {
+				return nil, invalid
+			}
+			parsed, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return nil, invalid
+			}
+			cursor.Values[i] = parsed
+		}
+	}
+
+	return &cursor, nil
+}
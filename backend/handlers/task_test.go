@@ -2,12 +2,24 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
 	"taskmanager-backend/backend/config"
+	"taskmanager-backend/backend/mailer"
+	"taskmanager-backend/backend/middlewares"
 	"taskmanager-backend/backend/models"
+	"taskmanager-backend/backend/recurrence"
+	"taskmanager-backend/backend/storage"
+	"taskmanager-backend/backend/trash"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/glebarez/sqlite"
@@ -20,7 +32,9 @@ func setupTestDB() {
 	if err != nil {
 		panic("failed to connect database")
 	}
-	db.AutoMigrate(&models.User{}, &models.Task{})
+	if err := models.Migrate(db); err != nil {
+		panic("failed to migrate database")
+	}
 	config.DB = db
 
 	// Seed user
@@ -37,20 +51,61 @@ func setupRouter() *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	r := gin.Default()
 
-	// Mock Auth Middleware
+	// Mock Auth Middleware; tests act as another user via X-Test-User
 	mockAuth := func(c *gin.Context) {
-		c.Set("user_id", uint(1))
+		userID := uint(1)
+		if raw := c.GetHeader("X-Test-User"); raw != "" {
+			id, _ := strconv.Atoi(raw)
+			userID = uint(id)
+		}
+		c.Set("user_id", userID)
 		c.Next()
 	}
 
+	r.POST("/api/webhook", HandleStripeWebhook)
+
 	api := r.Group("/api")
-	api.Use(mockAuth)
+	api.Use(mockAuth, middlewares.IdempotencyMiddleware())
 	{
 		api.POST("/tasks", CreateTask)
+		api.POST("/admin/users/:id/credits", AddUserCredits)
+		api.POST("/admin/clawbacks/:id/resolve", ResolveClawback)
 		api.GET("/tasks", GetTasks)
+		api.GET("/tasks/search", SearchTasks)
+		api.POST("/tasks/bulk", BulkTasks)
+		api.GET("/tasks/trash", GetTrash)
+		api.POST("/tasks/:id/restore", RestoreTask)
+		api.DELETE("/tasks/trash/:id", PurgeTask)
+		api.GET("/tasks/ready", GetTaskPlan)
 		api.GET("/tasks/:id", GetTask)
 		api.PUT("/tasks/:id", UpdateTask)
+		api.PATCH("/tasks/:id", PatchTask)
 		api.DELETE("/tasks/:id", DeleteTask)
+		api.GET("/tasks/:id/children", GetSubtasks)
+		api.PUT("/tasks/:id/parent", ReparentTask)
+		api.PUT("/tasks/:id/children/order", ReorderSubtasks)
+		api.GET("/tasks/:id/dependencies", GetTaskDependencies)
+		api.POST("/tasks/:id/dependencies", AddTaskDependency)
+		api.POST("/tasks/:id/tags", AttachTaskTags)
+		api.POST("/tasks/:id/comments", CreateComment)
+		api.PUT("/tasks/:id/comments/:comment_id", UpdateComment)
+		api.GET("/tasks/:id/comments/:comment_id/history", GetCommentHistory)
+		api.GET("/tasks/:id/attachments", GetAttachments)
+		api.POST("/tasks/:id/attachments", UploadAttachment)
+		api.GET("/tasks/:id/attachments/:attachment_id", DownloadAttachment)
+		api.GET("/tasks/:id/history", GetTaskHistory)
+		api.POST("/tasks/:id/history/:event_id/revert", RevertTask)
+		api.POST("/tags", CreateTag)
+		api.PUT("/projects/:id", UpdateProject)
+		api.DELETE("/projects/:id", DeleteProject)
+		api.GET("/projects/:id/tasks", GetProjectTasks)
+		api.GET("/projects/:id/stats", GetProjectStats)
+		api.POST("/workspaces", CreateWorkspace)
+		api.GET("/workspaces/:id", GetWorkspace)
+		api.PUT("/workspaces/:id/members/:user_id", UpdateWorkspaceMember)
+		api.POST("/workspaces/:id/invites", CreateWorkspaceInvite)
+		api.POST("/workspaces/:id/transfer", TransferWorkspace)
+		api.POST("/invites/accept", AcceptWorkspaceInvite)
 	}
 	return r
 }
@@ -92,7 +147,1010 @@ func TestGetTasks(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, w.Code)
 
+	var resp struct {
+		Data       []models.Task `json:"data"`
+		NextCursor *string       `json:"next_cursor"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.Equal(t, 2, len(resp.Data))
+	assert.Nil(t, resp.NextCursor)
+}
+
+func TestGetTasksFilterSortAndPaginate(t *testing.T) {
+	setupTestDB()
+	r := setupRouter()
+
+	config.DB.Create(&models.Task{Title: "A", Status: "pending", Priority: "low", UserID: 1})
+	config.DB.Create(&models.Task{Title: "B", Status: "pending", Priority: "high", UserID: 1})
+	config.DB.Create(&models.Task{Title: "C", Status: "completed", Priority: "high", UserID: 1})
+	config.DB.Create(&models.Task{Title: "D", Status: "pending", Priority: "medium", UserID: 1})
+	config.DB.Create(&models.Task{Title: "E", Status: "pending", Priority: "high", UserID: 2})
+
+	type page struct {
+		Data       []models.Task `json:"data"`
+		NextCursor *string       `json:"next_cursor"`
+	}
+	fetch := func(url string) (int, page) {
+		req, _ := http.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var p page
+		json.Unmarshal(w.Body.Bytes(), &p)
+		return w.Code, p
+	}
+
+	code, first := fetch("/api/tasks?status=pending&sort=-priority&limit=2")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"B", "D"}, []string{first.Data[0].Title, first.Data[1].Title})
+	assert.NotNil(t, first.NextCursor)
+
+	code, second := fetch("/api/tasks?status=pending&sort=-priority&limit=2&cursor=" + *first.NextCursor)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 1, len(second.Data))
+	assert.Equal(t, "A", second.Data[0].Title)
+	assert.Nil(t, second.NextCursor)
+
+	code, _ = fetch("/api/tasks?sort=title&cursor=" + *first.NextCursor)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = fetch("/api/tasks?sort=password")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestSearchTasks(t *testing.T) {
+	setupTestDB()
+	r := setupRouter()
+
+	config.DB.Create(&models.Task{Title: "Quarterly report", Description: "Draft the budget section", UserID: 1})
+	config.DB.Create(&models.Task{Title: "Groceries", Description: "Buy milk for the budget meeting", UserID: 1})
+	config.DB.Create(&models.Task{Title: "Budget review", Description: "Someone else's task", UserID: 2})
+	deleted := models.Task{Title: "Old budget", UserID: 1}
+	config.DB.Create(&deleted)
+	config.DB.Delete(&deleted)
+
+	req, _ := http.NewRequest("GET", "/api/tasks/search?q=budget", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Data []struct {
+			models.Task
+			Rank float64 `json:"rank"`
+		} `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.Equal(t, 2, len(resp.Data))
+
+	// Operator characters are stripped rather than passed to the index.
+	req, _ = http.NewRequest("GET", "/api/tasks/search?q=%22milk%22+OR+NEAR(", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestSubtaskHierarchy(t *testing.T) {
+	setupTestDB()
+	r := setupRouter()
+
+	parent := models.Task{Title: "Parent", Status: "pending", UserID: 1}
+	config.DB.Create(&parent)
+	child1 := models.Task{Title: "Child 1", Status: "completed", UserID: 1, ParentID: &parent.ID}
+	child2 := models.Task{Title: "Child 2", Status: "pending", UserID: 1, ParentID: &parent.ID, Position: 1}
+	config.DB.Create(&child1)
+	config.DB.Create(&child2)
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/tasks/%d", parent.ID), nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var got models.Task
+	json.Unmarshal(w.Body.Bytes(), &got)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.InDelta(t, 0.5, *got.Progress, 0.001)
+
+	// Moving the parent under its own child is a cycle.
+	body := fmt.Sprintf(`{"parent_id": %d}`, child1.ID)
+	req, _ = http.NewRequest("PUT", fmt.Sprintf("/api/tasks/%d/parent", parent.ID), bytes.NewBufferString(body))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Moving a task based on an outdated copy of it is refused.
+	req, _ = http.NewRequest("PUT", fmt.Sprintf("/api/tasks/%d/parent", child1.ID), bytes.NewBufferString(`{"parent_id": null}`))
+	req.Header.Set("If-Match", `"outdated"`)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	body = fmt.Sprintf(`{"order": [%d, %d]}`, child2.ID, child1.ID)
+	req, _ = http.NewRequest("PUT", fmt.Sprintf("/api/tasks/%d/children/order", parent.ID), bytes.NewBufferString(body))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var children []models.Task
+	json.Unmarshal(w.Body.Bytes(), &children)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, child2.ID, children[0].ID)
+
+	req, _ = http.NewRequest("DELETE", fmt.Sprintf("/api/tasks/%d?children=cascade", parent.ID), nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var remaining int64
+	config.DB.Model(&models.Task{}).Count(&remaining)
+	assert.Equal(t, int64(0), remaining)
+}
+
+func TestTaskDependencies(t *testing.T) {
+	setupTestDB()
+	r := setupRouter()
+
+	design := models.Task{Title: "Design", Status: "pending", Priority: "low", UserID: 1}
+	build := models.Task{Title: "Build", Status: "pending", Priority: "high", UserID: 1}
+	ship := models.Task{Title: "Ship", Status: "pending", Priority: "high", UserID: 1}
+	config.DB.Create(&design)
+	config.DB.Create(&build)
+	config.DB.Create(&ship)
+
+	link := func(task, blocker uint) int {
+		body := fmt.Sprintf(`{"blocker_id": %d}`, blocker)
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/tasks/%d/dependencies", task), bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusCreated, link(build.ID, design.ID))
+	assert.Equal(t, http.StatusCreated, link(ship.ID, build.ID))
+	assert.Equal(t, http.StatusBadRequest, link(design.ID, ship.ID))
+
+	body := `{"title": "Build", "status": "completed", "priority": "high"}`
+	req, _ := http.NewRequest("PUT", fmt.Sprintf("/api/tasks/%d", build.ID), bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	req, _ = http.NewRequest("GET", "/api/tasks/ready", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var plan struct {
+		Data []struct {
+			models.Task
+			Ready bool `json:"ready"`
+		} `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &plan)
+	assert.Equal(t, 3, len(plan.Data))
+	assert.Equal(t, "Design", plan.Data[0].Title)
+	assert.True(t, plan.Data[0].Ready)
+	assert.False(t, plan.Data[2].Ready)
+
+	// A blocker the caller can't see still holds the task back, but isn't
+	// revealed by the dependency listing.
+	config.DB.Create(&models.User{ID: 2, Name: "Bob", Email: "bob@example.com", Password: "x"})
+	hidden := models.Task{Title: "Hidden", Status: "pending", UserID: 2}
+	config.DB.Create(&hidden)
+	config.DB.Create(&models.TaskDependency{TaskID: design.ID, BlockerID: hidden.ID})
+
+	req, _ = http.NewRequest("GET", "/api/tasks/ready", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	json.Unmarshal(w.Body.Bytes(), &plan)
+	assert.Equal(t, 3, len(plan.Data))
+	for _, p := range plan.Data {
+		assert.False(t, p.Ready, p.Title)
+	}
+
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/api/tasks/%d/dependencies", design.ID), nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "Hidden")
+}
+
+func TestCompletingRecurringTaskSpawnsNextOccurrence(t *testing.T) {
+	setupTestDB()
+	r := setupRouter()
+
+	body := `{"title": "Water plants", "due_date": "2026-03-02T09:00:00Z", "recurrence_rule": "FREQ=WEEKLY;BYDAY=MO,TH"}`
+	req, _ := http.NewRequest("POST", "/api/tasks", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var created models.Task
+	json.Unmarshal(w.Body.Bytes(), &created)
+
+	body = `{"title": "Water plants", "status": "completed", "priority": "medium", "due_date": "2026-03-02T09:00:00Z"}`
+	req, _ = http.NewRequest("PUT", fmt.Sprintf("/api/tasks/%d", created.ID), bytes.NewBufferString(body))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var updated models.Task
+	json.Unmarshal(w.Body.Bytes(), &updated)
+	assert.NotNil(t, updated.NextOccurrenceID)
+
+	var next models.Task
+	config.DB.First(&next, *updated.NextOccurrenceID)
+	assert.Equal(t, "pending", next.Status)
+	assert.True(t, next.DueDate.After(time.Now()))
+	assert.Contains(t, []time.Weekday{time.Monday, time.Thursday}, next.DueDate.Weekday())
+
+	// The occurrence has a history of its own, starting with its creation.
+	var events []models.TaskEvent
+	config.DB.Where("task_id = ?", next.ID).Find(&events)
+	assert.Len(t, events, 1)
+	assert.Equal(t, models.TaskEventCreated, events[0].Action)
+
+	// Both the original task and the generated occurrence cost a credit.
+	var user models.User
+	config.DB.First(&user, 1)
+	assert.Equal(t, 3, user.Credits)
+
+	// A series its owner can't pay for is retried later, not every run.
+	config.DB.Model(&models.User{}).Where("id = ?", 1).Update("credits", 0)
+	now := next.DueDate.Add(time.Minute)
+	_, errs := recurrence.SpawnDue(config.DB, now)
+	assert.Len(t, errs, 1)
+	_, errs = recurrence.SpawnDue(config.DB, now.Add(time.Minute))
+	assert.Empty(t, errs)
+	spawned, errs := recurrence.SpawnDue(config.DB, now.Add(recurrence.PaymentRetryInterval))
+	assert.Len(t, errs, 1)
+	assert.Zero(t, spawned)
+}
+
+func TestTaskStatusWorkflow(t *testing.T) {
+	setupTestDB()
+	r := setupRouter()
+
+	task := models.Task{Title: "Flow", Status: "pending", Priority: "low", UserID: 1}
+	config.DB.Create(&task)
+
+	put := func(body string) (int, models.Task) {
+		req, _ := http.NewRequest("PUT", fmt.Sprintf("/api/tasks/%d", task.ID), bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var got models.Task
+		json.Unmarshal(w.Body.Bytes(), &got)
+		return w.Code, got
+	}
+
+	code, _ := put(`{"title": "Flow", "status": "archived", "priority": "low"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+
+	code, _ = put(`{"title": "Flow", "status": "pending", "priority": "urgent"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+
+	code, got := put(`{"title": "Flow", "status": "completed", "priority": "low"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotNil(t, got.CompletedAt)
+
+	// A completed task has to be reopened before it is worked on again.
+	req, _ := http.NewRequest("PUT", fmt.Sprintf("/api/tasks/%d", task.ID), bytes.NewBufferString(`{"title": "Flow", "status": "in-progress", "priority": "low"}`))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), `cannot move task from \"completed\" to \"in-progress\"`)
+
+	code, got = put(`{"title": "Flow", "status": "pending", "priority": "low"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Nil(t, got.CompletedAt)
+
+	code, _ = put(`{"title": "Flow", "status": "in-progress", "priority": "low"}`)
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestMigrateNormalizesLegacyTaskValues(t *testing.T) {
+	setupTestDB()
+
+	config.DB.Exec("INSERT INTO tasks (title, status, priority, user_id) VALUES ('a', 'Done', 'urgent', 1), ('b', 'weird', NULL, 1)")
+	assert.NoError(t, models.Migrate(config.DB))
+
 	var tasks []models.Task
-	json.Unmarshal(w.Body.Bytes(), &tasks)
-	assert.Equal(t, 2, len(tasks))
+	config.DB.Order("id").Find(&tasks)
+	assert.Equal(t, models.StatusCompleted, tasks[0].Status)
+	assert.Equal(t, models.PriorityHigh, tasks[0].Priority)
+	assert.NotNil(t, tasks[0].CompletedAt)
+	assert.Equal(t, models.StatusPending, tasks[1].Status)
+	assert.Equal(t, models.PriorityMedium, tasks[1].Priority)
+}
+
+func TestTaskTagsAndTagFilters(t *testing.T) {
+	setupTestDB()
+	r := setupRouter()
+
+	tagIDs := map[string]uint{}
+	for _, name := range []string{"home", "urgent"} {
+		req, _ := http.NewRequest("POST", "/api/tags", bytes.NewBufferString(fmt.Sprintf(`{"name": %q}`, name)))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var tag models.Tag
+		json.Unmarshal(w.Body.Bytes(), &tag)
+		tagIDs[name] = tag.ID
+	}
+
+	both := models.Task{Title: "Both", UserID: 1}
+	homeOnly := models.Task{Title: "Home only", UserID: 1}
+	config.DB.Create(&both)
+	config.DB.Create(&homeOnly)
+	config.DB.Create(&models.Task{Title: "Untagged", UserID: 1})
+
+	attach := func(taskID uint, ids ...uint) int {
+		body, _ := json.Marshal(gin.H{"tag_ids": ids})
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/tasks/%d/tags", taskID), bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusOK, attach(both.ID, tagIDs["home"], tagIDs["urgent"]))
+	assert.Equal(t, http.StatusOK, attach(homeOnly.ID, tagIDs["home"]))
+	assert.Equal(t, http.StatusBadRequest, attach(homeOnly.ID, 999))
+
+	// Tagging shows up in the task's history like any other change.
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/tasks/%d/history", homeOnly.ID), nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var events []models.TaskEvent
+	json.Unmarshal(w.Body.Bytes(), &events)
+	assert.Len(t, events, 1)
+	assert.Equal(t, "[]", string(events[0].Changes["tags"].From))
+	assert.Equal(t, fmt.Sprintf("[%d]", tagIDs["home"]), string(events[0].Changes["tags"].To))
+
+	list := func(url string) []models.Task {
+		req, _ := http.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var resp struct {
+			Data []models.Task `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp.Data
+	}
+
+	anyTasks := list("/api/tasks?tags=home,urgent")
+	assert.Equal(t, 2, len(anyTasks))
+	assert.Equal(t, 2, len(anyTasks[0].Tags))
+
+	allTasks := list("/api/tasks?tags=home,urgent&tag_match=all")
+	assert.Equal(t, 1, len(allTasks))
+	assert.Equal(t, "Both", allTasks[0].Title)
+
+	// On a shared task, another member's tag of the same name doesn't
+	// stand in for a tag the task lacks.
+	config.DB.Create(&models.User{ID: 2, Name: "Bob", Email: "bob@example.com", Password: "x"})
+	workspace := models.Workspace{Name: "Team", OwnerID: 1}
+	config.DB.Create(&workspace)
+	config.DB.Create(&models.WorkspaceMember{WorkspaceID: workspace.ID, UserID: 1, Role: models.WorkspaceRoleOwner})
+	config.DB.Create(&models.WorkspaceMember{WorkspaceID: workspace.ID, UserID: 2, Role: models.WorkspaceRoleEditor})
+	shared := models.Task{Title: "Shared", UserID: 1, WorkspaceID: &workspace.ID}
+	config.DB.Create(&shared)
+	bobsHome := models.Tag{Name: "home", UserID: 2}
+	config.DB.Create(&bobsHome)
+	config.DB.Exec("INSERT INTO task_tags (task_id, tag_id) VALUES (?, ?), (?, ?)", shared.ID, tagIDs["home"], shared.ID, bobsHome.ID)
+
+	allTasks = list("/api/tasks?tags=home,urgent&tag_match=all")
+	assert.Equal(t, 1, len(allTasks))
+	assert.Equal(t, "Both", allTasks[0].Title)
+
+	// Viewers can see a shared task but not retag it.
+	config.DB.Model(&models.WorkspaceMember{}).Where("user_id = ?", 2).Update("role", models.WorkspaceRoleViewer)
+	body, _ := json.Marshal(gin.H{"tag_ids": []uint{bobsHome.ID}})
+	req, _ = http.NewRequest("POST", fmt.Sprintf("/api/tasks/%d/tags", shared.ID), bytes.NewBuffer(body))
+	req.Header.Set("X-Test-User", "2")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestArchivedProjectHidesTasks(t *testing.T) {
+	setupTestDB()
+	r := setupRouter()
+
+	project := models.Project{Name: "Garden", UserID: 1}
+	config.DB.Create(&project)
+	config.DB.Create(&models.Task{Title: "Plant", Status: "completed", UserID: 1, ProjectID: &project.ID})
+	config.DB.Create(&models.Task{Title: "Weed", Status: "pending", UserID: 1, ProjectID: &project.ID})
+	config.DB.Create(&models.Task{Title: "Loose", Status: "pending", UserID: 1})
+
+	count := func(url string) int {
+		req, _ := http.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var resp struct {
+			Data []models.Task `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return len(resp.Data)
+	}
+	assert.Equal(t, 3, count("/api/tasks"))
+
+	req, _ := http.NewRequest("PUT", fmt.Sprintf("/api/projects/%d", project.ID), bytes.NewBufferString(`{"name": "Garden", "archived": true}`))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	assert.Equal(t, 1, count("/api/tasks"))
+	assert.Equal(t, 3, count("/api/tasks?include_archived=true"))
+	assert.Equal(t, 2, count(fmt.Sprintf("/api/projects/%d/tasks", project.ID)))
+
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/api/projects/%d/stats", project.ID), nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var stats struct {
+		TotalTasks     int64   `json:"total_tasks"`
+		CompletionRate float64 `json:"completion_rate"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &stats)
+	assert.Equal(t, int64(2), stats.TotalTasks)
+	assert.InDelta(t, 0.5, stats.CompletionRate, 0.001)
+
+	// Someone else's archived project doesn't hide a shared task, and their
+	// private tasks don't show up in the caller's stats.
+	config.DB.Create(&models.User{ID: 2, Name: "Bob", Email: "bob@example.com", Password: "x"})
+	workspace := models.Workspace{Name: "Team", OwnerID: 1}
+	config.DB.Create(&workspace)
+	config.DB.Create(&models.WorkspaceMember{WorkspaceID: workspace.ID, UserID: 1, Role: models.WorkspaceRoleOwner})
+	config.DB.Create(&models.WorkspaceMember{WorkspaceID: workspace.ID, UserID: 2, Role: models.WorkspaceRoleEditor})
+	bobsProject := models.Project{Name: "Bob's", UserID: 2, Archived: true}
+	config.DB.Create(&bobsProject)
+	config.DB.Create(&models.Task{Title: "Shared", UserID: 2, WorkspaceID: &workspace.ID, ProjectID: &bobsProject.ID})
+	config.DB.Create(&models.Task{Title: "Private", Status: "pending", UserID: 2, ProjectID: &project.ID})
+	assert.Equal(t, 2, count("/api/tasks"))
+
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/api/projects/%d/stats", project.ID), nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	json.Unmarshal(w.Body.Bytes(), &stats)
+	assert.Equal(t, int64(2), stats.TotalTasks)
+}
+
+func TestWorkspaceMembershipControlsTaskAccess(t *testing.T) {
+	setupTestDB()
+	r := setupRouter()
+	config.DB.Create(&models.User{ID: 2, Name: "Bob", Email: "bob@example.com", Password: "x"})
+
+	do := func(user uint, method, url, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("X-Test-User", strconv.Itoa(int(user)))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(1, "POST", "/api/workspaces", `{"name": "Team"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var workspace models.Workspace
+	json.Unmarshal(w.Body.Bytes(), &workspace)
+
+	outbox, _ := mailer.NewOutbox(t.TempDir(), "App <app@example.com>")
+	config.Mailer = outbox
+	w = do(1, "POST", fmt.Sprintf("/api/workspaces/%d/invites", workspace.ID), `{"email": "Bob@example.com", "role": "viewer"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.NotContains(t, w.Body.String(), "token")
+	messages, _ := outbox.Messages()
+	assert.Len(t, messages, 1)
+	assert.Equal(t, "bob@example.com", messages[0].To)
+	token := regexp.MustCompile(`token=([^\s]+)`).FindStringSubmatch(messages[0].Text)[1]
+
+	w = do(2, "POST", "/api/invites/accept", fmt.Sprintf(`{"token": %q}`, token))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w = do(2, "POST", "/api/invites/accept", fmt.Sprintf(`{"token": %q}`, token))
+	assert.Equal(t, http.StatusGone, w.Code)
+
+	// Members see each other's name, email and role, not account details.
+	config.DB.Model(&models.User{}).Where("id = ?", 1).Update("stripe_customer_id", "cus_secret")
+	w = do(2, "GET", fmt.Sprintf("/api/workspaces/%d", workspace.ID), "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var detail struct {
+		Members []map[string]interface{} `json:"members"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &detail)
+	assert.Len(t, detail.Members, 2)
+	assert.Equal(t, "test@example.com", detail.Members[0]["email"])
+	assert.Equal(t, "owner", detail.Members[0]["role"])
+	assert.NotContains(t, w.Body.String(), "cus_secret")
+	assert.NotContains(t, w.Body.String(), "credits")
+
+	w = do(1, "POST", "/api/tasks", fmt.Sprintf(`{"title": "Shared", "workspace_id": %d}`, workspace.ID))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var task models.Task
+	json.Unmarshal(w.Body.Bytes(), &task)
+	taskURL := fmt.Sprintf("/api/tasks/%d", task.ID)
+	update := `{"title": "Shared", "status": "in-progress", "priority": "high"}`
+
+	assert.Equal(t, http.StatusOK, do(2, "GET", taskURL, "").Code)
+	assert.Equal(t, http.StatusForbidden, do(2, "PUT", taskURL, update).Code)
+
+	w = do(1, "PUT", fmt.Sprintf("/api/workspaces/%d/members/2", workspace.ID), `{"role": "editor"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusOK, do(2, "PUT", taskURL, update).Code)
+
+	w = do(1, "POST", fmt.Sprintf("/api/workspaces/%d/transfer", workspace.ID), `{"user_id": 2}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = do(1, "PUT", fmt.Sprintf("/api/workspaces/%d/members/2", workspace.ID), `{"role": "viewer"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Personal tasks stay private to their creator.
+	personal := models.Task{Title: "Mine", UserID: 1}
+	config.DB.Create(&personal)
+	assert.Equal(t, http.StatusNotFound, do(2, "GET", fmt.Sprintf("/api/tasks/%d", personal.ID), "").Code)
+}
+
+func TestTaskComments(t *testing.T) {
+	setupTestDB()
+	r := setupRouter()
+	config.DB.Create(&models.User{ID: 2, Name: "Bob", Email: "bob@example.com", Password: "x"})
+
+	do := func(user uint, method, url, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("X-Test-User", strconv.Itoa(int(user)))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	task := models.Task{Title: "Discuss", UserID: 1}
+	config.DB.Create(&task)
+	commentsURL := fmt.Sprintf("/api/tasks/%d/comments", task.ID)
+
+	w := do(1, "POST", commentsURL, `{"body": "First **draft**"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var comment models.Comment
+	json.Unmarshal(w.Body.Bytes(), &comment)
+	commentURL := fmt.Sprintf("%s/%d", commentsURL, comment.ID)
+
+	// Other users can't see the task, let alone its comments.
+	assert.Equal(t, http.StatusNotFound, do(2, "POST", commentsURL, `{"body": "hi"}`).Code)
+
+	w = do(1, "PUT", commentURL, `{"body": "Second _draft_"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &comment)
+	assert.Equal(t, "Second _draft_", comment.Body)
+	assert.NotNil(t, comment.EditedAt)
+
+	w = do(1, "GET", commentURL+"/history", "")
+	var history []models.CommentRevision
+	json.Unmarshal(w.Body.Bytes(), &history)
+	assert.Len(t, history, 1)
+	assert.Equal(t, "First **draft**", history[0].Body)
+
+	w = do(1, "GET", fmt.Sprintf("/api/tasks/%d", task.ID), "")
+	var detail models.Task
+	json.Unmarshal(w.Body.Bytes(), &detail)
+	assert.Equal(t, int64(1), *detail.CommentCount)
+
+	assert.Equal(t, http.StatusOK, do(1, "DELETE", fmt.Sprintf("/api/tasks/%d", task.ID), "").Code)
+	var remaining, all int64
+	config.DB.Model(&models.Comment{}).Where("task_id = ?", task.ID).Count(&remaining)
+	config.DB.Unscoped().Model(&models.Comment{}).Where("task_id = ?", task.ID).Count(&all)
+	assert.Equal(t, int64(0), remaining)
+	assert.Equal(t, int64(1), all)
+}
+
+func TestTaskAttachments(t *testing.T) {
+	setupTestDB()
+	r := setupRouter()
+	files, err := storage.NewLocal(t.TempDir())
+	assert.NoError(t, err)
+	config.Storage = files
+
+	task := models.Task{Title: "With files", UserID: 1}
+	config.DB.Create(&task)
+	attachmentsURL := fmt.Sprintf("/api/tasks/%d/attachments", task.ID)
+
+	upload := func(name string, content []byte) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, _ := form.CreateFormFile("file", name)
+		part.Write(content)
+		form.Close()
+
+		req, _ := http.NewRequest("POST", attachmentsURL, &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// The type comes from the contents, whatever the name says.
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 64)...)
+	w := upload("../notes.txt", png)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var attachment models.Attachment
+	json.Unmarshal(w.Body.Bytes(), &attachment)
+	assert.Equal(t, "image/png", attachment.ContentType)
+	assert.Equal(t, "notes.txt", attachment.FileName)
+	assert.Equal(t, int64(len(png)), attachment.Size)
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf("%s/%d", attachmentsURL, attachment.ID), nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, png, w.Body.Bytes())
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "attachment")
+
+	// Free plan files are capped per upload.
+	big := make([]byte, models.StorageQuotaFor("free").MaxFileSize+1)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, upload("big.bin", big).Code)
+
+	// Files stay while the task is in the trash and go when it is purged.
+	config.DB.First(&attachment, attachment.ID)
+	for _, url := range []string{"/api/tasks/%d", "/api/tasks/trash/%d"} {
+		_, err = files.Open(context.Background(), attachment.StorageKey)
+		assert.NoError(t, err)
+
+		req, _ = http.NewRequest("DELETE", fmt.Sprintf(url, task.ID), nil)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	var remaining int64
+	config.DB.Model(&models.Attachment{}).Count(&remaining)
+	assert.Equal(t, int64(0), remaining)
+	_, err = files.Open(context.Background(), attachment.StorageKey)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestTaskHistoryAndRevert(t *testing.T) {
+	setupTestDB()
+	r := setupRouter()
+
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/api/tasks", `{"title": "Draft", "priority": "low"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var task models.Task
+	json.Unmarshal(w.Body.Bytes(), &task)
+	taskURL := fmt.Sprintf("/api/tasks/%d", task.ID)
+
+	w = do("PUT", taskURL, `{"title": "Final", "priority": "high", "status": "completed"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	// Saving the same values again changes nothing and records nothing.
+	do("PUT", taskURL, `{"title": "Final", "priority": "high", "status": "completed"}`)
+
+	var events []models.TaskEvent
+	json.Unmarshal(do("GET", taskURL+"/history", "").Body.Bytes(), &events)
+	assert.Len(t, events, 2)
+	assert.Equal(t, models.TaskEventCreated, events[0].Action)
+	assert.Equal(t, models.TaskEventUpdated, events[1].Action)
+	assert.Equal(t, `"Draft"`, string(events[1].Changes["title"].From))
+	assert.Equal(t, `"Final"`, string(events[1].Changes["title"].To))
+	assert.NotContains(t, events[1].Changes, "description")
+
+	// A revert based on an outdated copy of the task is refused.
+	revertURL := fmt.Sprintf("%s/history/%d/revert", taskURL, events[0].ID)
+	req, _ := http.NewRequest("POST", revertURL, nil)
+	req.Header.Set("If-Match", `"outdated"`)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	w = do("POST", revertURL, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &task)
+	assert.Equal(t, "Draft", task.Title)
+	assert.Equal(t, models.PriorityLow, task.Priority)
+	assert.Equal(t, models.StatusPending, task.Status)
+	assert.Nil(t, task.CompletedAt)
+
+	json.Unmarshal(do("GET", taskURL+"/history", "").Body.Bytes(), &events)
+	assert.Len(t, events, 3)
+	assert.Equal(t, models.TaskEventReverted, events[2].Action)
+
+	// Reverting restores the old status even where the workflow wouldn't
+	// allow that move directly.
+	do("PUT", taskURL, `{"title": "Draft", "priority": "low", "status": "in-progress"}`)
+	do("PUT", taskURL, `{"title": "Draft", "priority": "low", "status": "completed"}`)
+	json.Unmarshal(do("GET", taskURL+"/history", "").Body.Bytes(), &events)
+	w = do("POST", fmt.Sprintf("%s/history/%d/revert", taskURL, events[3].ID), "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &task)
+	assert.Equal(t, models.StatusInProgress, task.Status)
+	assert.Nil(t, task.CompletedAt)
+}
+
+func TestDeletingParentOrProjectIsRecorded(t *testing.T) {
+	setupTestDB()
+	r := setupRouter()
+
+	project := models.Project{Name: "Garden", UserID: 1}
+	config.DB.Create(&project)
+	parent := models.Task{Title: "Parent", Status: "pending", Priority: "medium", UserID: 1}
+	config.DB.Create(&parent)
+	child := models.Task{Title: "Child", Status: "pending", Priority: "medium", UserID: 1, ParentID: &parent.ID, ProjectID: &project.ID}
+	config.DB.Create(&child)
+
+	for _, url := range []string{fmt.Sprintf("/api/tasks/%d", parent.ID), fmt.Sprintf("/api/projects/%d", project.ID)} {
+		req, _ := http.NewRequest("DELETE", url, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	var events []models.TaskEvent
+	config.DB.Where("task_id = ?", child.ID).Order("id").Find(&events)
+	assert.Len(t, events, 2)
+	for _, event := range events {
+		assert.Equal(t, models.TaskEventUpdated, event.Action)
+	}
+	assert.Equal(t, fmt.Sprint(parent.ID), string(events[0].Changes["parent_id"].From))
+	assert.Equal(t, "null", string(events[0].Changes["parent_id"].To))
+	assert.Equal(t, fmt.Sprint(project.ID), string(events[1].Changes["project_id"].From))
+	assert.Equal(t, "null", string(events[1].Changes["project_id"].To))
+}
+
+func TestPatchTaskMergesFields(t *testing.T) {
+	setupTestDB()
+	r := setupRouter()
+
+	due := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	task := models.Task{Title: "Report", Description: "Quarterly numbers", Status: "pending", Priority: "medium", Assignee: "ana", DueDate: &due, UserID: 1}
+	config.DB.Create(&task)
+
+	patch := func(body string) (int, models.Task) {
+		req, _ := http.NewRequest("PATCH", fmt.Sprintf("/api/tasks/%d", task.ID), bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var got models.Task
+		json.Unmarshal(w.Body.Bytes(), &got)
+		return w.Code, got
+	}
+
+	code, got := patch(`{"status": "in-progress"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "in-progress", got.Status)
+	assert.Equal(t, "Quarterly numbers", got.Description)
+	assert.Equal(t, "ana", got.Assignee)
+	assert.NotNil(t, got.DueDate)
+
+	code, got = patch(`{"due_date": null, "assignee": null}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Nil(t, got.DueDate)
+	assert.Equal(t, "", got.Assignee)
+	assert.Equal(t, "Report", got.Title)
+
+	code, _ = patch(`{"title": null}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = patch(`{"priority": "urgent"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	code, _ = patch(`{"user_id": 2}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	// PUT still replaces the whole task.
+	req, _ := http.NewRequest("PUT", fmt.Sprintf("/api/tasks/%d", task.ID), bytes.NewBufferString(`{"title": "Report"}`))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestTaskETags(t *testing.T) {
+	setupTestDB()
+	r := setupRouter()
+
+	task := models.Task{Title: "Shared doc", Status: "pending", Priority: "medium", UserID: 1}
+	config.DB.Create(&task)
+	taskURL := fmt.Sprintf("/api/tasks/%d", task.ID)
+
+	do := func(method, url, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("GET", taskURL, "", nil)
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+	assert.Equal(t, http.StatusNotModified, do("GET", taskURL, "", map[string]string{"If-None-Match": etag}).Code)
+
+	list := do("GET", "/api/tasks", "", nil)
+	assert.Equal(t, http.StatusNotModified, do("GET", "/api/tasks", "", map[string]string{"If-None-Match": list.Header().Get("ETag")}).Code)
+
+	// The first tab saves with the ETag it loaded ...
+	update := `{"title": "Shared doc", "status": "in-progress", "priority": "medium"}`
+	w = do("PUT", taskURL, update, map[string]string{"If-Match": etag})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+
+	// ... so the second tab's stale ETag no longer matches.
+	assert.Equal(t, http.StatusPreconditionFailed, do("PATCH", taskURL, `{"title": "Mine"}`, map[string]string{"If-Match": etag, "Content-Type": "application/json"}).Code)
+	assert.Equal(t, http.StatusPreconditionFailed, do("DELETE", taskURL, "", map[string]string{"If-Match": etag}).Code)
+	assert.Equal(t, http.StatusOK, do("GET", taskURL, "", map[string]string{"If-None-Match": etag}).Code)
+	assert.Equal(t, http.StatusOK, do("DELETE", taskURL, "", map[string]string{"If-Match": w.Header().Get("ETag")}).Code)
+}
+
+func TestBulkTaskOperations(t *testing.T) {
+	setupTestDB()
+	r := setupRouter()
+
+	existing := models.Task{Title: "Old", Status: "pending", Priority: "low", UserID: 1}
+	config.DB.Create(&existing)
+
+	bulk := func(body string) (int, []BulkResult) {
+		req, _ := http.NewRequest("POST", "/api/tasks/bulk", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var resp struct {
+			Results []BulkResult `json:"results"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp.Results
+	}
+	credits := func() int {
+		var user models.User
+		config.DB.First(&user, 1)
+		return user.Credits
+	}
+
+	// One bad operation doesn't stop the others.
+	code, results := bulk(fmt.Sprintf(`{"operations": [
+		{"op": "create", "task": {"title": "A"}},
+		{"op": "create", "task": {"title": "B", "priority": "urgent"}},
+		{"op": "status", "id": %d, "status": "in-progress"}
+	]}`, existing.ID))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, http.StatusCreated, results[0].Status)
+	assert.Equal(t, http.StatusUnprocessableEntity, results[1].Status)
+	assert.Equal(t, http.StatusOK, results[2].Status)
+	assert.Equal(t, "in-progress", results[2].Task.Status)
+	assert.Equal(t, 4, credits())
+
+	// All or nothing: the failed delete undoes the create before it.
+	code, results = bulk(`{"all_or_nothing": true, "operations": [
+		{"op": "create", "task": {"title": "C"}},
+		{"op": "delete", "id": 999}
+	]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	assert.Equal(t, http.StatusNotFound, results[1].Status)
+	assert.Equal(t, 4, credits())
+	var count int64
+	config.DB.Model(&models.Task{}).Where("title = ?", "C").Count(&count)
+	assert.Equal(t, int64(0), count)
+
+	// Creates must be covered by the balance up front.
+	ops := make([]string, 5)
+	for i := range ops {
+		ops[i] = fmt.Sprintf(`{"op": "create", "task": {"title": "T%d"}}`, i)
+	}
+	code, _ = bulk(`{"operations": [` + strings.Join(ops, ",") + `]}`)
+	assert.Equal(t, http.StatusForbidden, code)
+	assert.Equal(t, 4, credits())
+
+	// Completing a recurring task links the occurrence it generates.
+	start := time.Now().Add(-time.Hour)
+	recurring := models.Task{Title: "Daily", Status: "pending", Priority: "low", UserID: 1,
+		DueDate: &start, RecurrenceRule: "FREQ=DAILY", RecurrenceStart: &start, RecurrenceIndex: 1}
+	config.DB.Create(&recurring)
+	code, results = bulk(fmt.Sprintf(`{"operations": [{"op": "status", "id": %d, "status": "completed"}]}`, recurring.ID))
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotNil(t, results[0].Task.NextOccurrenceID)
+}
+
+func TestTrashRestoreAndPurge(t *testing.T) {
+	setupTestDB()
+	r := setupRouter()
+
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	parent := models.Task{Title: "Move house", UserID: 1}
+	config.DB.Create(&parent)
+	child := models.Task{Title: "Pack books", UserID: 1, ParentID: &parent.ID}
+	config.DB.Create(&child)
+	do("POST", fmt.Sprintf("/api/tasks/%d/comments", parent.ID), `{"body": "Boxes are in the garage"}`)
+
+	assert.Equal(t, http.StatusOK, do("DELETE", fmt.Sprintf("/api/tasks/%d?children=cascade", parent.ID), "").Code)
+	assert.Equal(t, http.StatusNotFound, do("GET", fmt.Sprintf("/api/tasks/%d", parent.ID), "").Code)
+
+	var trashed struct {
+		Data []TrashedTask `json:"data"`
+	}
+	json.Unmarshal(do("GET", "/api/tasks/trash", "").Body.Bytes(), &trashed)
+	assert.Len(t, trashed.Data, 2)
+	assert.True(t, trashed.Data[0].PurgeAt.After(trashed.Data[0].DeletedAt))
+
+	w := do("POST", fmt.Sprintf("/api/tasks/%d/restore", parent.ID), "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var restored models.Task
+	json.Unmarshal(w.Body.Bytes(), &restored)
+	assert.Equal(t, int64(1), *restored.CommentCount)
+	assert.Equal(t, http.StatusOK, do("GET", fmt.Sprintf("/api/tasks/%d", child.ID), "").Code)
+
+	// A task restored after it was picked for purging keeps its comments.
+	_, err := trash.Purge(config.DB, []uint{parent.ID})
+	assert.NoError(t, err)
+	var kept int64
+	config.DB.Model(&models.Comment{}).Where("task_id = ?", parent.ID).Count(&kept)
+	assert.Equal(t, int64(1), kept)
+
+	// Only trashed tasks can be purged; the job purges what has expired.
+	assert.Equal(t, http.StatusNotFound, do("DELETE", fmt.Sprintf("/api/tasks/trash/%d", parent.ID), "").Code)
+	do("DELETE", fmt.Sprintf("/api/tasks/%d?children=cascade", parent.ID), "")
+
+	files, _ := storage.NewLocal(t.TempDir())
+	purged, err := trash.PurgeExpired(config.DB, files, time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, purged)
+
+	var left, comments int64
+	config.DB.Unscoped().Model(&models.Task{}).Count(&left)
+	config.DB.Unscoped().Model(&models.Comment{}).Count(&comments)
+	assert.Equal(t, int64(0), left)
+	assert.Equal(t, int64(0), comments)
+}
+
+func TestIdempotencyKeyReplaysCreate(t *testing.T) {
+	setupTestDB()
+	r := setupRouter()
+
+	create := func(key, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/tasks", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", key)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	first := create("retry-1", `{"title": "Once"}`)
+	assert.Equal(t, http.StatusCreated, first.Code)
+
+	// The retry gets the original response without charging again.
+	retry := create("retry-1", `{"title": "Once"}`)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+
+	var tasks int64
+	var user models.User
+	config.DB.Model(&models.Task{}).Count(&tasks)
+	config.DB.First(&user, 1)
+	assert.Equal(t, int64(1), tasks)
+	assert.Equal(t, 4, user.Credits)
+
+	// Reusing the key for another request is an error; other users have
+	// their own keys.
+	assert.Equal(t, http.StatusUnprocessableEntity, create("retry-1", `{"title": "Twice"}`).Code)
+	req, _ := http.NewRequest("POST", "/api/tasks", bytes.NewBufferString(`{"title": "Once"}`))
+	req.Header.Set("Idempotency-Key", "retry-1")
+	req.Header.Set("X-Test-User", "2")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.NotEqual(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Empty(t, w.Header().Get("Idempotent-Replayed"))
+
+	// A handler that panics releases the key, so the retry runs for real.
+	calls := 0
+	flaky := gin.New()
+	flaky.Use(gin.Recovery(), func(c *gin.Context) { c.Set("user_id", uint(1)) }, middlewares.IdempotencyMiddleware())
+	flaky.POST("/flaky", func(c *gin.Context) {
+		calls++
+		if calls == 1 {
+			panic("boom")
+		}
+		c.JSON(http.StatusOK, gin.H{"calls": calls})
+	})
+	for _, want := range []int{http.StatusInternalServerError, http.StatusOK} {
+		req, _ := http.NewRequest("POST", "/flaky", nil)
+		req.Header.Set("Idempotency-Key", "flaky-1")
+		w := httptest.NewRecorder()
+		flaky.ServeHTTP(w, req)
+		assert.Equal(t, want, w.Code)
+	}
 }
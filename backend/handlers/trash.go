@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"net/http"
+	"taskmanager-backend/backend/config"
+	"taskmanager-backend/backend/history"
+	"taskmanager-backend/backend/models"
+	"taskmanager-backend/backend/trash"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// TrashedTask is a deleted task and when it will be purged for good.
+type TrashedTask struct {
+	models.Task
+	DeletedAt time.Time `json:"deleted_at"`
+	PurgeAt   time.Time `json:"purge_at"`
+}
+
+// findTrashedTask loads a task from the trash that the caller may modify.
+func findTrashedTask(c *gin.Context, db *gorm.DB, id interface{}) (models.Task, error) {
+	var task models.Task
+	err := scopeTasks(c, db.Unscoped().Model(&models.Task{}), models.WorkspaceWriteRoles).
+		Where("tasks.id = ? AND tasks.deleted_at IS NOT NULL", id).
+		First(&task).Error
+	return task, err
+}
+
+// GetTrash lists deleted tasks the caller may restore, most recently
+// deleted first.
+func GetTrash(c *gin.Context) {
+	var tasks []models.Task
+	if err := scopeTasks(c, config.DB.Unscoped().Model(&models.Task{}), models.WorkspaceWriteRoles).
+		Where("tasks.deleted_at IS NOT NULL").
+		Order("tasks.deleted_at DESC, tasks.id").
+		Find(&tasks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch trash"})
+		return
+	}
+
+	retention := trash.Retention()
+	trashed := make([]TrashedTask, len(tasks))
+	for i, t := range tasks {
+		trashed[i] = TrashedTask{Task: t, DeletedAt: t.DeletedAt.Time, PurgeAt: t.DeletedAt.Time.Add(retention)}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": trashed})
+}
+
+// RestoreTask takes a task out of the trash, along with the subtasks and
+// comments that were deleted with it.
+func RestoreTask(c *gin.Context) {
+	tx := config.DB.Begin()
+
+	task, err := findTrashedTask(c, tx, c.Param("id"))
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found in trash"})
+		return
+	}
+	deletedAt := task.DeletedAt.Time
+
+	ids := []uint{task.ID}
+	frontier := []uint{task.ID}
+	for depth := 0; len(frontier) > 0 && depth <= maxTaskDepth; depth++ {
+		var next []uint
+		if err := tx.Unscoped().Model(&models.Task{}).Where("parent_id IN ? AND deleted_at = ?", frontier, deletedAt).Pluck("id", &next).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore task"})
+			return
+		}
+		ids = append(ids, next...)
+		frontier = next
+	}
+
+	if err := tx.Unscoped().Model(&models.Task{}).Where("id IN ?", ids).Update("deleted_at", nil).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore task"})
+		return
+	}
+	if err := tx.Unscoped().Model(&models.Comment{}).Where("task_id IN ? AND deleted_at = ?", ids, deletedAt).Update("deleted_at", nil).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore task"})
+		return
+	}
+
+	// The parent or project may have gone away in the meantime
+	if task.ParentID != nil {
+		var parents int64
+		tx.Model(&models.Task{}).Where("id = ?", *task.ParentID).Count(&parents)
+		if parents == 0 {
+			position, err := nextChildPosition(tx, task.UserID, nil)
+			if err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore task"})
+				return
+			}
+			if err := tx.Model(&models.Task{}).Where("id = ?", task.ID).Updates(map[string]interface{}{"parent_id": nil, "position": position}).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore task"})
+				return
+			}
+		}
+	}
+	if task.ProjectID != nil {
+		var projects int64
+		tx.Model(&models.Project{}).Where("id = ?", *task.ProjectID).Count(&projects)
+		if projects == 0 {
+			if err := tx.Model(&models.Task{}).Where("project_id = ? AND id IN ?", *task.ProjectID, ids).Update("project_id", nil).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore task"})
+				return
+			}
+		}
+	}
+
+	userID, _ := c.Get("user_id")
+	for _, id := range ids {
+		if err := history.Record(tx, id, userID.(uint), models.TaskEventRestored, nil); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore task"})
+			return
+		}
+	}
+
+	tx.Commit()
+
+	respondTask(c, http.StatusOK, task.ID)
+}
+
+// PurgeTask permanently deletes a task that is already in the trash.
+func PurgeTask(c *gin.Context) {
+	tx := config.DB.Begin()
+
+	task, err := findTrashedTask(c, tx, c.Param("id"))
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found in trash"})
+		return
+	}
+
+	files, err := trash.Purge(tx, []uint{task.ID})
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete task permanently"})
+		return
+	}
+	tx.Commit()
+	removeStoredFiles(files)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Task deleted permanently"})
+}
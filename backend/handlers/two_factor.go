@@ -0,0 +1,367 @@
+package handlers
+
+import (
+	"net/http"
+	"taskmanager-backend/backend/config"
+	"taskmanager-backend/backend/models"
+	"taskmanager-backend/backend/totp"
+	"taskmanager-backend/backend/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// twoFactorIssuer names the account in authenticator apps.
+	twoFactorIssuer = "Task Manager"
+	mfaLoginPurpose = "mfa-login"
+	// mfaTokenLifespan is how long a user has to enter their code after
+	// giving the right password.
+	mfaTokenLifespan = 5 * time.Minute
+	// After twoFactorMaxFailures wrong codes in a row, second-step logins
+	// are refused for twoFactorLockout.
+	twoFactorMaxFailures = 5
+	twoFactorLockout     = 15 * time.Minute
+	recoveryCodeCount    = 10
+)
+
+// mfaPendingToken is handed out by Login in place of a session when the
+// user has two-factor authentication enabled; LoginTwoFactor trades it and
+// a code for the session. It is bound to the password hash and TOTP
+// secret, so changing either invalidates it.
+func mfaPendingToken(user models.User) string {
+	return utils.SignLinkToken(mfaLoginPurpose, user.ID, mfaLoginState(user), time.Now().Add(mfaTokenLifespan))
+}
+
+func mfaLoginState(user models.User) string {
+	return user.Password + "\x00" + user.TwoFactorSecret
+}
+
+// verifySecondFactor checks code against the user's authenticator and,
+// failing that, their unused recovery codes. A matching code is used up.
+func verifySecondFactor(db *gorm.DB, user models.User, code string) (bool, error) {
+	if step, ok := totp.Validate(user.TwoFactorSecret, code, time.Now(), user.TwoFactorLastStep); ok {
+		// The condition stops two requests racing to use the same code.
+		result := db.Model(&models.User{}).Where("id = ? AND two_factor_last_step < ?", user.ID, step).
+			UpdateColumn("two_factor_last_step", step)
+		return result.RowsAffected == 1, result.Error
+	}
+
+	result := db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashRecoveryCode(code)).
+		UpdateColumn("used_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+// hashRecoveryCode returns the value stored for a recovery code. The codes
+// carry 80 random bits, enough for a fast hash.
+func hashRecoveryCode(code string) string {
+	return utils.HashOpaqueToken(totp.NormalizeRecoveryCode(code))
+}
+
+// replaceRecoveryCodes discards the user's recovery codes and returns a
+// fresh set. The codes are shown once; only their hashes are kept.
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	rows := make([]models.RecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		code, err := totp.NewRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		rows[i] = models.RecoveryCode{UserID: userID, CodeHash: hashRecoveryCode(code)}
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// currentUser loads the user behind the request, answering for the
+// handler if it can't.
+func currentUser(c *gin.Context) (models.User, bool) {
+	var user models.User
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return user, false
+	}
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return user, false
+	}
+	return user, true
+}
+
+type EnrollTwoFactorInput struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// EnrollTwoFactor starts two-factor enrollment by generating a TOTP
+// secret. Two-factor authentication isn't enforced until the user proves
+// their authenticator works with ConfirmTwoFactor.
+func EnrollTwoFactor(c *gin.Context) {
+	var input EnrollTwoFactorInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if user.TwoFactorEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	if !utils.CheckPasswordHash(input.Password, user.Password) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Incorrect password"})
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not start enrollment"})
+		return
+	}
+	if err := config.DB.Model(&user).UpdateColumns(map[string]interface{}{
+		"two_factor_secret":    secret,
+		"two_factor_last_step": 0,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not start enrollment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_url": totp.URI(twoFactorIssuer, user.Email, secret),
+	})
+}
+
+type TwoFactorCodeInput struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// ConfirmTwoFactor turns two-factor authentication on once the user
+// enters a code from their newly enrolled authenticator, and returns
+// their recovery codes.
+func ConfirmTwoFactor(c *gin.Context) {
+	var input TwoFactorCodeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if user.TwoFactorEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	if user.TwoFactorSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Start two-factor enrollment first"})
+		return
+	}
+	step, valid := totp.Validate(user.TwoFactorSecret, input.Code, time.Now(), user.TwoFactorLastStep)
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid authentication code"})
+		return
+	}
+
+	tx := config.DB.Begin()
+	if err := tx.Model(&user).UpdateColumns(map[string]interface{}{
+		"two_factor_enabled":   true,
+		"two_factor_last_step": step,
+		"two_factor_failures":  0,
+	}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not enable two-factor authentication"})
+		return
+	}
+	codes, err := replaceRecoveryCodes(tx, user.ID)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not enable two-factor authentication"})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication enabled", "recovery_codes": codes})
+}
+
+type DisableTwoFactorInput struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// DisableTwoFactor turns two-factor authentication off. It takes the
+// password and a current code or recovery code, so a stolen session alone
+// can't remove it.
+func DisableTwoFactor(c *gin.Context) {
+	var input DisableTwoFactorInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if !user.TwoFactorEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+	if !utils.CheckPasswordHash(input.Password, user.Password) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Incorrect password"})
+		return
+	}
+
+	tx := config.DB.Begin()
+	valid, err := verifySecondFactor(tx, user, input.Code)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not disable two-factor authentication"})
+		return
+	}
+	if !valid {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid authentication code"})
+		return
+	}
+	if err := tx.Model(&user).UpdateColumns(map[string]interface{}{
+		"two_factor_enabled":      false,
+		"two_factor_secret":       "",
+		"two_factor_last_step":    0,
+		"two_factor_failures":     0,
+		"two_factor_locked_until": nil,
+	}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not disable two-factor authentication"})
+		return
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not disable two-factor authentication"})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes, for when
+// they have run low or the old ones may have been seen.
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var input TwoFactorCodeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if !user.TwoFactorEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+
+	tx := config.DB.Begin()
+	valid, err := verifySecondFactor(tx, user, input.Code)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create recovery codes"})
+		return
+	}
+	if !valid {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid authentication code"})
+		return
+	}
+	codes, err := replaceRecoveryCodes(tx, user.ID)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create recovery codes"})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+type LoginTwoFactorInput struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// LoginTwoFactor is the second step of Login for users with two-factor
+// authentication: it exchanges the pending token and a TOTP or recovery
+// code for a session.
+func LoginTwoFactor(c *gin.Context) {
+	var input LoginTwoFactorInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := utils.LinkTokenUser(input.MFAToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login has expired; sign in again"})
+		return
+	}
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil || !user.TwoFactorEnabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login has expired; sign in again"})
+		return
+	}
+	if err := utils.VerifyLinkToken(mfaLoginPurpose, input.MFAToken, mfaLoginState(user)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login has expired; sign in again"})
+		return
+	}
+	if user.TwoFactorLockedUntil != nil && time.Now().Before(*user.TwoFactorLockedUntil) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many incorrect codes; try again later"})
+		return
+	}
+
+	valid, err := verifySecondFactor(config.DB, user, input.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check authentication code"})
+		return
+	}
+	if !valid {
+		if err := recordTwoFactorFailure(user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check authentication code"})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication code"})
+		return
+	}
+
+	if user.TwoFactorFailures > 0 {
+		config.DB.Model(&user).UpdateColumn("two_factor_failures", 0)
+	}
+	startSession(c, user)
+}
+
+// recordTwoFactorFailure counts a wrong code against the user, locking
+// second-step logins once there have been too many in a row.
+func recordTwoFactorFailure(user models.User) error {
+	// Count in the database, not from the row read earlier, so parallel
+	// guesses can't each write the same count and dodge the lockout.
+	if err := config.DB.Model(&user).UpdateColumn("two_factor_failures", gorm.Expr("two_factor_failures + 1")).Error; err != nil {
+		return err
+	}
+	return config.DB.Model(&models.User{}).
+		Where("id = ? AND two_factor_failures >= ?", user.ID, twoFactorMaxFailures).
+		UpdateColumns(map[string]interface{}{
+			"two_factor_failures":     0,
+			"two_factor_locked_until": time.Now().Add(twoFactorLockout),
+		}).Error
+}
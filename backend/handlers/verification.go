@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"taskmanager-backend/backend/config"
+	"taskmanager-backend/backend/mailer"
+	"taskmanager-backend/backend/models"
+	"taskmanager-backend/backend/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	verifyEmailPurpose = "verify-email"
+	// verificationLinkLifespan is how long an emailed verification link works.
+	verificationLinkLifespan = 24 * time.Hour
+	// verificationResendInterval throttles resending the link.
+	verificationResendInterval = time.Minute
+)
+
+// sendVerificationEmail mails user a link that verifies their address.
+// The link is bound to the address, so it stops working if it changes.
+func sendVerificationEmail(ctx context.Context, user models.User) error {
+	token := utils.SignLinkToken(verifyEmailPurpose, user.ID, user.Email, time.Now().Add(verificationLinkLifespan))
+	link := config.AppURL() + "/verify-email?token=" + url.QueryEscape(token)
+
+	if err := config.Mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Text: fmt.Sprintf("Hi %s,\n\nConfirm your email address by opening this link within 24 hours:\n\n%s\n\n"+
+			"If you didn't sign up, you can ignore this message.\n", user.Name, link),
+	}); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return config.DB.Model(&user).UpdateColumn("verification_sent_at", now).Error
+}
+
+type VerifyEmailInput struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// VerifyEmail marks the account behind a verification link as verified.
+func VerifyEmail(c *gin.Context) {
+	var input VerifyEmailInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := utils.LinkTokenUser(input.Token)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid verification link"})
+		return
+	}
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid verification link"})
+		return
+	}
+
+	switch err := utils.VerifyLinkToken(verifyEmailPurpose, input.Token, user.Email); {
+	case errors.Is(err, utils.ErrExpiredLinkToken):
+		c.JSON(http.StatusGone, gin.H{"error": "Verification link has expired; request a new one"})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid verification link"})
+		return
+	}
+
+	if !user.Verified {
+		if err := config.DB.Model(&user).UpdateColumn("verified", true).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify email"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified"})
+}
+
+// ResendVerification sends the current user a fresh verification link.
+func ResendVerification(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.Verified {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email is already verified"})
+		return
+	}
+	if user.VerificationSentAt != nil && time.Since(*user.VerificationSentAt) < verificationResendInterval {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "A verification email was just sent; try again in a minute"})
+		return
+	}
+
+	if err := sendVerificationEmail(c.Request.Context(), user); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Could not send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
+
+// requireVerified answers 403 and returns false unless the current user
+// has verified their email address.
+func requireVerified(c *gin.Context, action string) bool {
+	userID, _ := c.Get("user_id")
+	var user models.User
+	if err := config.DB.Select("verified").First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return false
+	}
+	if !user.Verified {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Verify your email address before " + action})
+		return false
+	}
+	return true
+}
+
+// unverifiedTaskLimit stops users who haven't verified their email from
+// creating more than models.UnverifiedTaskLimit tasks, and counts the task
+// being created. Creations are counted rather than live tasks, so deleting
+// tasks doesn't make room for more.
+func unverifiedTaskLimit(tx *gorm.DB, userID uint) *taskError {
+	counted := tx.Model(&models.User{}).
+		Where("id = ? AND (verified = ? OR tasks_created < ?)", userID, true, models.UnverifiedTaskLimit).
+		UpdateColumn("tasks_created", gorm.Expr("tasks_created + 1"))
+	if counted.Error != nil {
+		return newTaskError(http.StatusInternalServerError, "Failed to count tasks")
+	}
+	if counted.RowsAffected == 0 {
+		return newTaskError(http.StatusForbidden, fmt.Sprintf("Verify your email address to create more than %d tasks", models.UnverifiedTaskLimit))
+	}
+	return nil
+}
@@ -0,0 +1,437 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"taskmanager-backend/backend/config"
+	"taskmanager-backend/backend/mailer"
+	"taskmanager-backend/backend/models"
+	"taskmanager-backend/backend/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const workspaceInviteLifetime = 7 * 24 * time.Hour
+
+// requireWorkspaceRole loads the workspace in the :id param and checks the
+// caller holds one of roles in it, answering 404/403 itself otherwise.
+func requireWorkspaceRole(c *gin.Context, roles []string) (models.Workspace, bool) {
+	var workspace models.Workspace
+	if err := config.DB.First(&workspace, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Workspace not found"})
+		return workspace, false
+	}
+
+	role := workspaceRole(c, config.DB, workspace.ID)
+	if role == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Workspace not found"})
+		return workspace, false
+	}
+	if !hasRole(role, roles) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: insufficient workspace role"})
+		return workspace, false
+	}
+	return workspace, true
+}
+
+type WorkspaceInput struct {
+	Name string `json:"name" binding:"required"`
+}
+
+func CreateWorkspace(c *gin.Context) {
+	var input WorkspaceInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	workspace := models.Workspace{Name: strings.TrimSpace(input.Name), OwnerID: userID.(uint)}
+
+	tx := config.DB.Begin()
+	if err := tx.Create(&workspace).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create workspace"})
+		return
+	}
+	owner := models.WorkspaceMember{WorkspaceID: workspace.ID, UserID: workspace.OwnerID, Role: models.WorkspaceRoleOwner}
+	if err := tx.Create(&owner).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create workspace"})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusCreated, workspace)
+}
+
+func GetWorkspaces(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var workspaces []struct {
+		models.Workspace
+		Role string `json:"role"`
+	}
+	if err := config.DB.Model(&models.Workspace{}).
+		Select("workspaces.*, workspace_members.role").
+		Joins("JOIN workspace_members ON workspace_members.workspace_id = workspaces.id").
+		Where("workspace_members.user_id = ?", userID).
+		Order("workspaces.name").
+		Scan(&workspaces).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch workspaces"})
+		return
+	}
+
+	c.JSON(http.StatusOK, workspaces)
+}
+
+// workspaceMember is what members of a workspace see of each other.
+type workspaceMember struct {
+	UserID uint   `json:"user_id"`
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+	Role   string `json:"role"`
+}
+
+func GetWorkspace(c *gin.Context) {
+	workspace, ok := requireWorkspaceRole(c, models.WorkspaceReadRoles)
+	if !ok {
+		return
+	}
+
+	var members []workspaceMember
+	if err := config.DB.Model(&models.WorkspaceMember{}).
+		Select("workspace_members.user_id, users.name, users.email, workspace_members.role").
+		Joins("JOIN users ON users.id = workspace_members.user_id").
+		Where("workspace_members.workspace_id = ?", workspace.ID).
+		Order("workspace_members.id").
+		Scan(&members).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch members"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": workspace, "members": members})
+}
+
+func UpdateWorkspace(c *gin.Context) {
+	workspace, ok := requireWorkspaceRole(c, []string{models.WorkspaceRoleOwner})
+	if !ok {
+		return
+	}
+
+	var input WorkspaceInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	workspace.Name = strings.TrimSpace(input.Name)
+	if err := config.DB.Save(&workspace).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update workspace"})
+		return
+	}
+
+	c.JSON(http.StatusOK, workspace)
+}
+
+// DeleteWorkspace removes the workspace and hands each of its tasks back to
+// the member who created it as a personal task.
+func DeleteWorkspace(c *gin.Context) {
+	workspace, ok := requireWorkspaceRole(c, []string{models.WorkspaceRoleOwner})
+	if !ok {
+		return
+	}
+
+	tx := config.DB.Begin()
+	if err := tx.Model(&models.Task{}).Unscoped().Where("workspace_id = ?", workspace.ID).Update("workspace_id", nil).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete workspace"})
+		return
+	}
+	if err := tx.Where("workspace_id = ?", workspace.ID).Delete(&models.WorkspaceMember{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete workspace"})
+		return
+	}
+	if err := tx.Where("workspace_id = ?", workspace.ID).Delete(&models.WorkspaceInvite{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete workspace"})
+		return
+	}
+	if err := tx.Delete(&workspace).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete workspace"})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Workspace deleted successfully"})
+}
+
+type UpdateMemberInput struct {
+	Role string `json:"role" binding:"required"`
+}
+
+func UpdateWorkspaceMember(c *gin.Context) {
+	workspace, ok := requireWorkspaceRole(c, []string{models.WorkspaceRoleOwner})
+	if !ok {
+		return
+	}
+
+	var input UpdateMemberInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Role != models.WorkspaceRoleEditor && input.Role != models.WorkspaceRoleViewer {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be 'editor' or 'viewer'; use transfer to change the owner"})
+		return
+	}
+
+	var member models.WorkspaceMember
+	if err := config.DB.Where("workspace_id = ? AND user_id = ?", workspace.ID, c.Param("user_id")).First(&member).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+	if member.Role == models.WorkspaceRoleOwner {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Transfer ownership before changing the owner's role"})
+		return
+	}
+
+	member.Role = input.Role
+	if err := config.DB.Save(&member).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update member"})
+		return
+	}
+
+	c.JSON(http.StatusOK, member)
+}
+
+// RemoveWorkspaceMember lets the owner remove anyone but themselves, and
+// lets any other member leave.
+func RemoveWorkspaceMember(c *gin.Context) {
+	workspace, ok := requireWorkspaceRole(c, models.WorkspaceReadRoles)
+	if !ok {
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	var member models.WorkspaceMember
+	if err := config.DB.Where("workspace_id = ? AND user_id = ?", workspace.ID, c.Param("user_id")).First(&member).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+
+	leaving := member.UserID == userID.(uint)
+	if !leaving && workspace.OwnerID != userID.(uint) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: insufficient workspace role"})
+		return
+	}
+	if member.Role == models.WorkspaceRoleOwner {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The owner must transfer ownership before leaving"})
+		return
+	}
+
+	if err := config.DB.Delete(&member).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+}
+
+type TransferWorkspaceInput struct {
+	UserID uint `json:"user_id" binding:"required"`
+}
+
+// TransferWorkspace makes another member the owner; the previous owner
+// stays on as an editor.
+func TransferWorkspace(c *gin.Context) {
+	workspace, ok := requireWorkspaceRole(c, []string{models.WorkspaceRoleOwner})
+	if !ok {
+		return
+	}
+
+	var input TransferWorkspaceInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx := config.DB.Begin()
+
+	var target models.WorkspaceMember
+	if err := tx.Where("workspace_id = ? AND user_id = ?", workspace.ID, input.UserID).First(&target).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "New owner must already be a member"})
+		return
+	}
+
+	if err := tx.Model(&models.WorkspaceMember{}).
+		Where("workspace_id = ? AND user_id = ?", workspace.ID, workspace.OwnerID).
+		Update("role", models.WorkspaceRoleEditor).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to transfer workspace"})
+		return
+	}
+	if err := tx.Model(&target).Update("role", models.WorkspaceRoleOwner).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to transfer workspace"})
+		return
+	}
+	workspace.OwnerID = target.UserID
+	if err := tx.Save(&workspace).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to transfer workspace"})
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusOK, workspace)
+}
+
+type InviteInput struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required"`
+}
+
+// CreateWorkspaceInvite returns a single-use invite token for an email
+// address. The token is only shown in this response.
+func CreateWorkspaceInvite(c *gin.Context) {
+	workspace, ok := requireWorkspaceRole(c, []string{models.WorkspaceRoleOwner})
+	if !ok {
+		return
+	}
+
+	var input InviteInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Role != models.WorkspaceRoleEditor && input.Role != models.WorkspaceRoleViewer {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be 'editor' or 'viewer'"})
+		return
+	}
+
+	token, err := utils.NewOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate invite"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	invite := models.WorkspaceInvite{
+		WorkspaceID: workspace.ID,
+		Email:       strings.ToLower(strings.TrimSpace(input.Email)),
+		Role:        input.Role,
+		TokenHash:   utils.HashOpaqueToken(token),
+		InvitedByID: userID.(uint),
+		ExpiresAt:   time.Now().Add(workspaceInviteLifetime),
+	}
+	if err := config.DB.Create(&invite).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create invite"})
+		return
+	}
+
+	if err := sendWorkspaceInvite(c.Request.Context(), workspace, invite, token); err != nil {
+		config.DB.Delete(&invite)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Could not send invite email"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": invite})
+}
+
+// sendWorkspaceInvite mails the invitee the link that accepts invite. The
+// token only ever travels in this message, never in an API response.
+func sendWorkspaceInvite(ctx context.Context, workspace models.Workspace, invite models.WorkspaceInvite, token string) error {
+	var inviter models.User
+	if err := config.DB.First(&inviter, invite.InvitedByID).Error; err != nil {
+		return err
+	}
+
+	link := config.AppURL() + "/invites/accept?token=" + url.QueryEscape(token)
+	return config.Mailer.Send(ctx, mailer.Message{
+		To:      invite.Email,
+		Subject: fmt.Sprintf("%s invited you to %s", inviter.Name, workspace.Name),
+		Text: fmt.Sprintf("Hi,\n\n%s invited you to join the workspace %q as %s. Sign in with this address and "+
+			"open this link within 7 days to accept:\n\n%s\n\nIf you weren't expecting this, you can ignore this message.\n",
+			inviter.Name, workspace.Name, invite.Role, link),
+	})
+}
+
+type AcceptInviteInput struct {
+	Token string `json:"token" binding:"required"`
+}
+
+func AcceptWorkspaceInvite(c *gin.Context) {
+	var input AcceptInviteInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	tx := config.DB.Begin()
+
+	var invite models.WorkspaceInvite
+	if err := tx.Where("token_hash = ?", utils.HashOpaqueToken(input.Token)).First(&invite).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+		return
+	}
+	if invite.AcceptedAt != nil || time.Now().After(invite.ExpiresAt) {
+		tx.Rollback()
+		c.JSON(http.StatusGone, gin.H{"error": "Invite has expired or was already used"})
+		return
+	}
+	if !strings.EqualFold(invite.Email, user.Email) {
+		tx.Rollback()
+		c.JSON(http.StatusForbidden, gin.H{"error": "This invite was sent to a different email address"})
+		return
+	}
+
+	var workspace models.Workspace
+	if err := tx.First(&workspace, invite.WorkspaceID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "Workspace not found"})
+		return
+	}
+
+	now := time.Now()
+	claim := tx.Model(&invite).Where("accepted_at IS NULL").Update("accepted_at", now)
+	if claim.Error != nil || claim.RowsAffected == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusGone, gin.H{"error": "Invite has expired or was already used"})
+		return
+	}
+
+	var existing models.WorkspaceMember
+	if err := tx.Where("workspace_id = ? AND user_id = ?", workspace.ID, user.ID).First(&existing).Error; err == nil {
+		tx.Commit()
+		c.JSON(http.StatusOK, existing)
+		return
+	}
+
+	member := models.WorkspaceMember{WorkspaceID: workspace.ID, UserID: user.ID, Role: invite.Role}
+	if err := tx.Create(&member).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join workspace"})
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusCreated, member)
+}
@@ -0,0 +1,119 @@
+// Package history records what happened to tasks, field by field, and can
+// roll a task back to an earlier state.
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"taskmanager-backend/backend/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Fields is the part of a task that history tracks and revert restores.
+type Fields struct {
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Status      string     `json:"status"`
+	Priority    string     `json:"priority"`
+	DueDate     *time.Time `json:"due_date"`
+	Assignee    string     `json:"assignee"`
+	ProjectID   *uint      `json:"project_id"`
+	ParentID    *uint      `json:"parent_id"`
+}
+
+// ErrEventNotFound is returned by StateAt when the event isn't part of
+// the task's history.
+var ErrEventNotFound = errors.New("history: event not found")
+
+// Snapshot returns the tracked fields of task.
+func Snapshot(task models.Task) Fields {
+	return Fields{
+		Title:       task.Title,
+		Description: task.Description,
+		Status:      task.Status,
+		Priority:    task.Priority,
+		DueDate:     task.DueDate,
+		Assignee:    task.Assignee,
+		ProjectID:   task.ProjectID,
+		ParentID:    task.ParentID,
+	}
+}
+
+func values(f Fields) map[string]json.RawMessage {
+	raw, _ := json.Marshal(f)
+	var out map[string]json.RawMessage
+	json.Unmarshal(raw, &out)
+	return out
+}
+
+// Diff lists the fields that differ between before and after. A nil
+// before describes a newly created task.
+func Diff(before *Fields, after Fields) map[string]models.FieldChange {
+	to := values(after)
+	changes := map[string]models.FieldChange{}
+	if before == nil {
+		for name, v := range to {
+			changes[name] = models.FieldChange{From: json.RawMessage("null"), To: v}
+		}
+		return changes
+	}
+
+	from := values(*before)
+	for name, v := range to {
+		if !bytes.Equal(from[name], v) {
+			changes[name] = models.FieldChange{From: from[name], To: v}
+		}
+	}
+	return changes
+}
+
+// Record writes an event for task, unless it is an update or revert that
+// changed nothing.
+func Record(tx *gorm.DB, taskID, actorID uint, action string, changes map[string]models.FieldChange) error {
+	if len(changes) == 0 && (action == models.TaskEventUpdated || action == models.TaskEventReverted) {
+		return nil
+	}
+	return tx.Create(&models.TaskEvent{
+		TaskID:  taskID,
+		ActorID: actorID,
+		Action:  action,
+		Changes: changes,
+	}).Error
+}
+
+// StateAt rebuilds the tracked fields of task as they were right after
+// eventID, by undoing every later event starting from the current state.
+func StateAt(db *gorm.DB, task models.Task, eventID uint) (Fields, error) {
+	var target models.TaskEvent
+	if err := db.Where("id = ? AND task_id = ?", eventID, task.ID).First(&target).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return Fields{}, ErrEventNotFound
+		}
+		return Fields{}, err
+	}
+
+	var later []models.TaskEvent
+	if err := db.Where("task_id = ? AND id > ?", task.ID, eventID).Order("id DESC").Find(&later).Error; err != nil {
+		return Fields{}, err
+	}
+
+	state := values(Snapshot(task))
+	for _, event := range later {
+		for name, change := range event.Changes {
+			if _, tracked := state[name]; tracked {
+				state[name] = change.From
+			}
+		}
+	}
+
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return Fields{}, err
+	}
+	var fields Fields
+	err = json.Unmarshal(raw, &fields)
+	return fields, err
+}
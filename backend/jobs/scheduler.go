@@ -0,0 +1,106 @@
+// Package jobs runs the backend's periodic background work.
+package jobs
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"taskmanager-backend/backend/models"
+	"taskmanager-backend/backend/recurrence"
+	"taskmanager-backend/backend/sessions"
+	"taskmanager-backend/backend/storage"
+	"taskmanager-backend/backend/trash"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// interval reads a period in seconds from the environment.
+func interval(env string, fallback time.Duration) time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv(env))
+	if err != nil || seconds <= 0 {
+		return fallback
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// StartRecurrenceScheduler generates the next occurrence of recurring
+// tasks as their due dates arrive. It runs until the process exits.
+func StartRecurrenceScheduler(db *gorm.DB) {
+	every := interval("RECURRENCE_INTERVAL_SECONDS", time.Minute)
+
+	go func() {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			spawned, errs := recurrence.SpawnDue(db, now)
+			for _, err := range errs {
+				log.Printf("Recurrence scheduler: %v", err)
+			}
+			if spawned > 0 {
+				log.Printf("Recurrence scheduler created %d occurrences", spawned)
+			}
+		}
+	}()
+}
+
+// StartTrashPurger permanently deletes tasks that have been in the trash
+// longer than trash.Retention(). It runs until the process exits.
+func StartTrashPurger(db *gorm.DB, files storage.Backend) {
+	every := interval("TRASH_PURGE_INTERVAL_SECONDS", time.Hour)
+	retention := trash.Retention()
+
+	go func() {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			purged, err := trash.PurgeExpired(db, files, now.Add(-retention))
+			if err != nil {
+				log.Printf("Trash purger: %v", err)
+			}
+			if purged > 0 {
+				log.Printf("Trash purger deleted %d tasks", purged)
+			}
+		}
+	}()
+}
+
+// StartIdempotencyKeyCleanup deletes stored Idempotency-Key responses once
+// they can no longer be replayed. It runs until the process exits.
+func StartIdempotencyKeyCleanup(db *gorm.DB) {
+	every := interval("IDEMPOTENCY_CLEANUP_INTERVAL_SECONDS", time.Hour)
+
+	go func() {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			result := db.Where("expires_at < ?", now).Delete(&models.IdempotencyKey{})
+			if result.Error != nil {
+				log.Printf("Idempotency key cleanup: %v", result.Error)
+			}
+			if result.RowsAffected > 0 {
+				log.Printf("Idempotency key cleanup deleted %d keys", result.RowsAffected)
+			}
+		}
+	}()
+}
+
+// StartTokenCleanup deletes refresh tokens and revoked access token
+// entries once they have expired. It runs until the process exits.
+func StartTokenCleanup(db *gorm.DB) {
+	every := interval("TOKEN_CLEANUP_INTERVAL_SECONDS", time.Hour)
+
+	go func() {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			deleted, err := sessions.Cleanup(db, now)
+			if err != nil {
+				log.Printf("Token cleanup: %v", err)
+			}
+			if deleted > 0 {
+				log.Printf("Token cleanup deleted %d expired tokens", deleted)
+			}
+		}
+	}()
+}
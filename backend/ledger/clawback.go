@@ -0,0 +1,125 @@
+package ledger
+
+import (
+	"fmt"
+	"os"
+	"taskmanager-backend/backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Policy decides what happens to the part of a clawback the user has
+// already spent.
+type Policy string
+
+const (
+	// PolicyNegative lets the net balance go negative: the shortfall is
+	// owed and repaid from the next credits the user gets.
+	PolicyNegative Policy = "negative"
+	// PolicyHold owes the shortfall like PolicyNegative and also blocks
+	// spending until it is repaid.
+	PolicyHold Policy = "hold"
+	// PolicyReview leaves the shortfall for an admin to recover or waive.
+	PolicyReview Policy = "review"
+)
+
+// PolicyFromEnv reads STRIPE_CLAWBACK_POLICY, defaulting to PolicyReview.
+func PolicyFromEnv() Policy {
+	switch p := Policy(os.Getenv("STRIPE_CLAWBACK_POLICY")); p {
+	case PolicyNegative, PolicyHold:
+		return p
+	}
+	return PolicyReview
+}
+
+// Clawback takes clawback.Credits back from the user. Whatever the balance
+// can cover is recovered; the policy handles the rest. It fills in the
+// outcome fields, records the ledger transaction and saves clawback. Like
+// Post it must run inside tx.
+func Clawback(tx *gorm.DB, clawback *models.Clawback, policy Policy) error {
+	recovered, owed, err := charge(tx, clawback.UserID, clawback.Credits, policy != PolicyReview, policy == PolicyHold)
+	if err != nil {
+		return err
+	}
+
+	clawback.Recovered = recovered
+	clawback.Shortfall = clawback.Credits - recovered
+	clawback.Charged = recovered + owed
+	switch {
+	case clawback.Shortfall == 0:
+		clawback.Status = models.ClawbackSettled
+	case owed > 0:
+		clawback.Status = models.ClawbackOwed
+	default:
+		clawback.Status = models.ClawbackPendingReview
+	}
+
+	if clawback.Charged > 0 {
+		transaction := models.Transaction{
+			UserID:      clawback.UserID,
+			Amount:      -clawback.Charged,
+			Type:        clawback.Reason,
+			Description: fmt.Sprintf("Clawed back %d credits (%s of %s)", clawback.Credits, clawback.Reason, clawback.PaymentIntentID),
+			StripeRef:   clawback.PaymentIntentID,
+		}
+		if err := tx.Create(&transaction).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Save(clawback).Error
+}
+
+// RecoverShortfall settles a clawback waiting for review by charging its
+// shortfall after all, owing whatever the balance still can't cover.
+func RecoverShortfall(tx *gorm.DB, clawback *models.Clawback) error {
+	recovered, owed, err := charge(tx, clawback.UserID, clawback.Shortfall, true, false)
+	if err != nil {
+		return err
+	}
+
+	transaction := models.Transaction{
+		UserID:      clawback.UserID,
+		Amount:      -(recovered + owed),
+		Type:        clawback.Reason,
+		Description: fmt.Sprintf("Recovered %d credits after review (%s of %s)", clawback.Shortfall, clawback.Reason, clawback.PaymentIntentID),
+		StripeRef:   clawback.PaymentIntentID,
+	}
+	if err := tx.Create(&transaction).Error; err != nil {
+		return err
+	}
+
+	clawback.Recovered += recovered
+	clawback.Charged += recovered + owed
+	clawback.Status = models.ClawbackOwed
+	if owed == 0 {
+		clawback.Status = models.ClawbackSettled
+	}
+	return tx.Save(clawback).Error
+}
+
+// charge takes up to amount from the user's balance and, if owe is set,
+// adds the remainder to what they owe. The user row is locked so the
+// balance can't change in between.
+func charge(tx *gorm.DB, userID uint, amount int, owe, hold bool) (recovered, owed int, err error) {
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+		return 0, 0, err
+	}
+
+	recovered = min(user.Credits, amount)
+	if owe {
+		owed = amount - recovered
+	}
+	updates := map[string]interface{}{
+		"credits":      gorm.Expr("credits - ?", recovered),
+		"credits_owed": gorm.Expr("credits_owed + ?", owed),
+	}
+	if hold && owed > 0 {
+		updates["credit_hold"] = true
+	}
+	if err := tx.Model(&user).UpdateColumns(updates).Error; err != nil {
+		return 0, 0, err
+	}
+	return recovered, owed, nil
+}
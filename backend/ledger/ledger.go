@@ -0,0 +1,66 @@
+package ledger
+
+import (
+	"errors"
+	"taskmanager-backend/backend/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInsufficientCredits = errors.New("insufficient credits")
+	ErrCreditHold          = errors.New("credits are on hold")
+)
+
+// Debit removes amount credits from the user and records the matching
+// negative transaction. It must run inside tx so the balance change and
+// the ledger row commit together.
+func Debit(tx *gorm.DB, userID uint, amount int, txType, description string) (*models.User, error) {
+	return Post(tx, &models.Transaction{UserID: userID, Amount: -amount, Type: txType, Description: description})
+}
+
+// Credit adds amount credits to the user and records the matching
+// positive transaction. Like Debit it must run inside tx.
+func Credit(tx *gorm.DB, userID uint, amount int, txType, description string) (*models.User, error) {
+	return Post(tx, &models.Transaction{UserID: userID, Amount: amount, Type: txType, Description: description})
+}
+
+// Post applies transaction.Amount to the user's balance and stores the
+// transaction, filling in its ID. The balance is checked and changed in a
+// single conditional UPDATE, so concurrent debits can't overspend. Debits
+// fail while the user's credits are on hold; credits repay CreditsOwed
+// before adding to the balance, and lift a hold once the debt is repaid.
+func Post(tx *gorm.DB, transaction *models.Transaction) (*models.User, error) {
+	amount := transaction.Amount
+	query := tx.Model(&models.User{}).Where("id = ?", transaction.UserID)
+	var result *gorm.DB
+	if amount < 0 {
+		result = query.Where("credits >= ? AND credit_hold = ?", -amount, false).
+			UpdateColumn("credits", gorm.Expr("credits + ?", amount))
+	} else {
+		result = query.UpdateColumns(map[string]interface{}{
+			"credits":      gorm.Expr("credits + CASE WHEN credits_owed >= ? THEN 0 ELSE ? - credits_owed END", amount, amount),
+			"credits_owed": gorm.Expr("CASE WHEN credits_owed >= ? THEN credits_owed - ? ELSE 0 END", amount, amount),
+			"credit_hold":  gorm.Expr("CASE WHEN credits_owed > 0 AND credits_owed <= ? THEN ? ELSE credit_hold END", amount, false),
+		})
+	}
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	var user models.User
+	if err := tx.First(&user, transaction.UserID).Error; err != nil {
+		return nil, err
+	}
+	if result.RowsAffected == 0 {
+		if user.CreditHold {
+			return nil, ErrCreditHold
+		}
+		return nil, ErrInsufficientCredits
+	}
+
+	if err := tx.Create(transaction).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
@@ -0,0 +1,51 @@
+package ledger
+
+import (
+	"errors"
+	"taskmanager-backend/backend/models"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func setupDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, models.Migrate(db))
+	return db
+}
+
+func TestDebitAndCreditKeepLedgerInSync(t *testing.T) {
+	db := setupDB(t)
+	user := models.User{Name: "Ann", Email: "ann@example.com", Credits: 2}
+	db.Create(&user)
+	db.Create(&models.Transaction{UserID: user.ID, Amount: 2, Type: "bonus"})
+
+	after, err := Debit(db, user.ID, 2, "usage", "Two tasks")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, after.Credits)
+
+	_, err = Debit(db, user.ID, 1, "usage", "One too many")
+	assert.ErrorIs(t, err, ErrInsufficientCredits)
+	_, err = Debit(db, 999, 1, "usage", "Nobody")
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+
+	after, err = Credit(db, user.ID, 3, "purchase", "Top up")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, after.Credits)
+
+	mismatches, err := Reconcile(db)
+	assert.NoError(t, err)
+	assert.Empty(t, mismatches)
+
+	// The database refuses a negative balance even outside the ledger.
+	assert.Error(t, db.Model(&user).UpdateColumn("credits", -1).Error)
+
+	// A balance changed without a transaction shows up.
+	db.Model(&user).UpdateColumn("credits", 10)
+	mismatches, err = Reconcile(db)
+	assert.NoError(t, err)
+	assert.Equal(t, []Mismatch{{UserID: user.ID, Email: "ann@example.com", Credits: 10, Ledger: 3}}, mismatches)
+}
@@ -0,0 +1,31 @@
+package ledger
+
+import (
+	"taskmanager-backend/backend/models"
+
+	"gorm.io/gorm"
+)
+
+// Mismatch is a user whose balance doesn't equal the sum of their
+// transactions. Credits is the net balance: spendable credits minus what
+// the user owes from clawbacks.
+type Mismatch struct {
+	UserID  uint
+	Email   string
+	Credits int
+	Ledger  int
+}
+
+// Reconcile compares every user's balance with their transaction history
+// and returns the users where the two disagree.
+func Reconcile(db *gorm.DB) ([]Mismatch, error) {
+	var mismatches []Mismatch
+	err := db.Model(&models.User{}).
+		Select("users.id AS user_id, users.email, users.credits - users.credits_owed AS credits, COALESCE(SUM(transactions.amount), 0) AS ledger").
+		Joins("LEFT JOIN transactions ON transactions.user_id = users.id").
+		Group("users.id, users.email, users.credits, users.credits_owed").
+		Having("users.credits - users.credits_owed <> COALESCE(SUM(transactions.amount), 0)").
+		Order("users.id").
+		Scan(&mismatches).Error
+	return mismatches, err
+}
@@ -0,0 +1,92 @@
+// Package mailer sends the application's transactional email.
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Message is a plain-text email to a single recipient.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+}
+
+// Mailer delivers messages.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// FromEnv builds the mailer selected by MAILER: "outbox" (the default,
+// which writes messages to OUTBOX_DIR instead of sending them) or "smtp"
+// (configured by the SMTP_* variables). MAIL_FROM sets the sender.
+func FromEnv() (Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "Task Manager <no-reply@localhost>"
+	}
+	if _, err := mail.ParseAddress(from); err != nil {
+		return nil, fmt.Errorf("mailer: invalid MAIL_FROM: %w", err)
+	}
+
+	switch backend := os.Getenv("MAILER"); backend {
+	case "", "outbox":
+		dir := os.Getenv("OUTBOX_DIR")
+		if dir == "" {
+			dir = "outbox"
+		}
+		return NewOutbox(dir, from)
+	case "smtp":
+		port, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
+		if err != nil {
+			port = 587
+		}
+		s := &SMTP{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}
+		if s.Host == "" {
+			return nil, errors.New("mailer: SMTP_HOST must be set")
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("mailer: unknown MAILER %q", backend)
+	}
+}
+
+// format renders msg as an RFC 5322 message from the given sender.
+func format(from string, msg Message) ([]byte, error) {
+	if _, err := mail.ParseAddress(msg.To); err != nil {
+		return nil, fmt.Errorf("mailer: invalid recipient: %w", err)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	body := quotedprintable.NewWriter(&buf)
+	if _, err := body.Write([]byte(msg.Text)); err != nil {
+		return nil, err
+	}
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
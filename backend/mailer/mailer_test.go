@@ -0,0 +1,89 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testMessage = Message{To: "ann@example.com", Subject: "Verify your émail", Text: "Open https://example.com/verify?token=abc\n"}
+
+func TestOutboxRoundTrip(t *testing.T) {
+	outbox, err := NewOutbox(t.TempDir(), "App <app@example.com>")
+	assert.NoError(t, err)
+	assert.NoError(t, outbox.Send(context.Background(), testMessage))
+	assert.Error(t, outbox.Send(context.Background(), Message{To: "not an address"}))
+
+	messages, err := outbox.Messages()
+	assert.NoError(t, err)
+	assert.Len(t, messages, 1)
+	assert.Equal(t, testMessage.To, messages[0].To)
+	assert.Equal(t, testMessage.Subject, messages[0].Subject)
+	assert.Equal(t, "Open https://example.com/verify?token=abc\r\n", messages[0].Text)
+}
+
+// fakeSMTP accepts one message and hands back its DATA section.
+func fakeSMTP(t *testing.T) (int, <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+		reply("220 fake ESMTP")
+		var data strings.Builder
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if inData {
+				if line == ".\r\n" {
+					inData = false
+					received <- data.String()
+					reply("250 queued")
+				} else {
+					data.WriteString(line)
+				}
+				continue
+			}
+			switch cmd := strings.ToUpper(strings.Fields(line)[0]); cmd {
+			case "EHLO", "HELO", "MAIL", "RCPT":
+				reply("250 ok")
+			case "DATA":
+				inData = true
+				reply("354 go ahead")
+			case "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("502 unknown")
+			}
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port, received
+}
+
+func TestSMTPSend(t *testing.T) {
+	port, received := fakeSMTP(t)
+	s := &SMTP{Host: "127.0.0.1", Port: port, From: "App <app@example.com>"}
+	assert.NoError(t, s.Send(context.Background(), testMessage))
+
+	data := <-received
+	assert.Contains(t, data, "To: ann@example.com\r\n")
+	assert.Contains(t, data, "Subject: =?utf-8?q?Verify_your_=C3=A9mail?=\r\n")
+	assert.Contains(t, data, "token=3Dabc")
+}
@@ -0,0 +1,73 @@
+package mailer
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Outbox writes each message to a .eml file instead of sending it, for
+// local development and tests. The files open in any mail client.
+type Outbox struct {
+	dir  string
+	from string
+	seq  atomic.Int64
+}
+
+// NewOutbox returns a mailer writing into dir, creating it if needed.
+func NewOutbox(dir, from string) (*Outbox, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	return &Outbox{dir: dir, from: from}, nil
+}
+
+func (o *Outbox) Send(_ context.Context, msg Message) error {
+	data, err := format(o.from, msg)
+	if err != nil {
+		return err
+	}
+	name := strconv.FormatInt(time.Now().UnixNano(), 10) + "-" + strconv.FormatInt(o.seq.Add(1), 10) + ".eml"
+	return os.WriteFile(filepath.Join(o.dir, name), data, 0o640)
+}
+
+// Messages reads back everything in the outbox, oldest first.
+func (o *Outbox) Messages() ([]Message, error) {
+	paths, err := filepath.Glob(filepath.Join(o.dir, "*.eml"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	messages := make([]Message, 0, len(paths))
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := mail.ReadMessage(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		body, err := io.ReadAll(quotedprintable.NewReader(parsed.Body))
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, Message{To: parsed.Header.Get("To"), Subject: subject, Text: string(body)})
+	}
+	return messages, nil
+}
@@ -0,0 +1,74 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+)
+
+// SMTP sends mail through a submission server, upgrading the connection
+// with STARTTLS when the server offers it. Credentials are only sent over
+// TLS (or to localhost), as net/smtp's PlainAuth enforces.
+type SMTP struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	data, err := format(s.From, msg)
+	if err != nil {
+		return err
+	}
+	from, _ := mail.ParseAddress(s.From)
+	to, _ := mail.ParseAddress(msg.To)
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(s.Host, strconv.Itoa(s.Port)))
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.Host}); err != nil {
+			return err
+		}
+	}
+	if s.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"taskmanager-backend/backend/config"
+	"taskmanager-backend/backend/jobs"
 	"taskmanager-backend/backend/models"
 	"taskmanager-backend/backend/routes"
 
@@ -19,6 +20,10 @@ func main() {
 	// Connect to Database
 	config.ConnectDB()
 
+	// Set up file storage for attachments
+	config.ConnectStorage()
+	config.ConnectMailer()
+
 	// Run Migrations
 	if err := models.Migrate(config.DB); err != nil {
 		log.Fatalf("Failed to run migrations: %v", err)
@@ -27,6 +32,12 @@ func main() {
 	// Seed Data
 	// seeds.Seed(config.DB)
 
+	// Start Background Jobs
+	jobs.StartRecurrenceScheduler(config.DB)
+	jobs.StartTrashPurger(config.DB, config.Storage)
+	jobs.StartIdempotencyKeyCleanup(config.DB)
+	jobs.StartTokenCleanup(config.DB)
+
 	// Setup Router
 	r := routes.SetupRouter()
 
@@ -5,7 +5,9 @@ import (
 	"strings"
 	"taskmanager-backend/backend/config"
 	"taskmanager-backend/backend/models"
+	"taskmanager-backend/backend/sessions"
 	"taskmanager-backend/backend/utils"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -29,6 +31,11 @@ func JwtAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		if strings.HasPrefix(tokenString, models.PersonalAccessTokenPrefix) {
+			authenticateAccessToken(c, tokenString)
+			return
+		}
+
 		token, err := utils.ValidateToken(tokenString)
 		if err != nil || !token.Valid {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API token"})
@@ -37,15 +44,86 @@ func JwtAuthMiddleware() gin.HandlerFunc {
 		}
 
 		claims, ok := token.Claims.(jwt.MapClaims)
-		if ok && token.Valid {
-			userID := uint(claims["user_id"].(float64))
-			c.Set("user_id", userID)
-			c.Next()
-		} else {
+		rawUserID, hasUser := claims["user_id"].(float64)
+		jti, hasJTI := claims["jti"].(string)
+		if !ok || !hasUser || !hasJTI || jti == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API token claims"})
 			c.Abort()
 			return
 		}
+
+		revoked, err := sessions.IsRevoked(config.DB, jti)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check API token"})
+			c.Abort()
+			return
+		}
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "API token has been revoked"})
+			c.Abort()
+			return
+		}
+
+		c.Set("user_id", uint(rawUserID))
+		if sid, ok := claims["sid"].(string); ok {
+			c.Set("session_id", sid)
+		}
+		c.Next()
+	}
+}
+
+// accessTokenUseInterval is how stale a personal access token's last-use
+// record may get before a request refreshes it, to save a write per call.
+const accessTokenUseInterval = time.Minute
+
+// authenticateAccessToken lets a personal access token stand in for a JWT.
+// The token is left in the context for RequireScope and SessionOnly.
+func authenticateAccessToken(c *gin.Context, raw string) {
+	var pat models.PersonalAccessToken
+	if err := config.DB.Where("token_hash = ?", utils.HashOpaqueToken(raw)).First(&pat).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API token"})
+		c.Abort()
+		return
+	}
+	now := time.Now()
+	if pat.ExpiresAt != nil && now.After(*pat.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "API token has expired"})
+		c.Abort()
+		return
+	}
+
+	if pat.LastUsedAt == nil || now.Sub(*pat.LastUsedAt) > accessTokenUseInterval || pat.LastUsedIP != c.ClientIP() {
+		config.DB.Model(&pat).UpdateColumns(map[string]interface{}{"last_used_at": now, "last_used_ip": c.ClientIP()})
+	}
+
+	c.Set("user_id", pat.UserID)
+	c.Set("personal_access_token", pat)
+	c.Next()
+}
+
+// RequireScope admits login sessions, and personal access tokens that
+// were granted scope.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if pat, ok := c.Get("personal_access_token"); ok && !pat.(models.PersonalAccessToken).HasScope(scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API token is missing the " + scope + " scope"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// SessionOnly refuses personal access tokens, for account and admin
+// routes that need someone to have logged in.
+func SessionOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("personal_access_token"); ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API tokens can't be used here; log in instead"})
+			c.Abort()
+			return
+		}
+		c.Next()
 	}
 }
 
@@ -71,6 +149,18 @@ func AdminAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		required, err := models.BoolSetting(config.DB, models.SettingAdminRequireTwoFactor)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check admin settings"})
+			c.Abort()
+			return
+		}
+		if required && !user.TwoFactorEnabled {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: enable two-factor authentication to use admin access"})
+			c.Abort()
+			return
+		}
+
 		c.Next()
 	}
 }
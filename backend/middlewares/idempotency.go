@@ -0,0 +1,204 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"taskmanager-backend/backend/config"
+	"taskmanager-backend/backend/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxIdempotencyKeyLength bounds the Idempotency-Key header.
+	maxIdempotencyKeyLength = 255
+	// idempotencyMemoryLimit is the largest body buffered in memory for
+	// hashing; bigger ones (uploads) are spooled to a temporary file.
+	idempotencyMemoryLimit = 1 << 20
+)
+
+// IdempotencyKeyTTL is how long a key's response is replayed, unless
+// IDEMPOTENCY_KEY_TTL_HOURS says otherwise.
+func IdempotencyKeyTTL() time.Duration {
+	hours, err := strconv.Atoi(os.Getenv("IDEMPOTENCY_KEY_TTL_HOURS"))
+	if err != nil || hours <= 0 {
+		return 24 * time.Hour
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// IdempotencyMiddleware makes mutating requests that carry an
+// Idempotency-Key header safe to retry. Keys are scoped to the
+// authenticated user: the first request runs and its response is stored,
+// later requests with the same key and body get that response replayed,
+// and reusing a key for a different request is rejected. Server errors
+// release the key so the request can be retried for real, as do handlers
+// that panic or abort.
+//
+// Responses are stored as they are sent, so this only belongs on routes
+// whose responses carry no secrets, never on account or admin routes.
+func IdempotencyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		userID, authenticated := c.Get("user_id")
+		if key == "" || !authenticated || !isMutating(c.Request.Method) {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			return
+		}
+
+		requestHash, cleanup, err := bufferRequestBody(c.Request)
+		defer cleanup()
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			return
+		}
+
+		now := time.Now()
+		record := models.IdempotencyKey{
+			UserID:      userID.(uint),
+			Key:         key,
+			Method:      c.Request.Method,
+			Path:        c.Request.URL.RequestURI(),
+			RequestHash: requestHash,
+			ExpiresAt:   now.Add(IdempotencyKeyTTL()),
+		}
+		if err := config.DB.Create(&record).Error; err != nil {
+			// The key exists already: replay, reject or take over an expired one
+			var existing models.IdempotencyKey
+			if err := config.DB.Where("user_id = ? AND idempotency_key = ?", record.UserID, key).First(&existing).Error; err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check Idempotency-Key"})
+				return
+			}
+
+			if existing.ExpiresAt.After(now) {
+				switch {
+				case existing.RequestHash != requestHash:
+					c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used for a different request"})
+				case existing.StatusCode == 0:
+					c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still being processed"})
+				default:
+					c.Header("Idempotent-Replayed", "true")
+					c.Data(existing.StatusCode, existing.ContentType, existing.ResponseBody)
+					c.Abort()
+				}
+				return
+			}
+
+			claimed := config.DB.Model(&models.IdempotencyKey{}).
+				Where("id = ? AND expires_at = ?", existing.ID, existing.ExpiresAt).
+				Updates(map[string]interface{}{
+					"method":        record.Method,
+					"path":          record.Path,
+					"request_hash":  record.RequestHash,
+					"status_code":   0,
+					"content_type":  "",
+					"response_body": nil,
+					"expires_at":    record.ExpiresAt,
+				})
+			if claimed.Error != nil || claimed.RowsAffected == 0 {
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still being processed"})
+				return
+			}
+			record.ID = existing.ID
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		finished := false
+		defer func() {
+			if !finished {
+				config.DB.Delete(&models.IdempotencyKey{}, record.ID)
+			}
+		}()
+		c.Next()
+
+		status := recorder.Status()
+		if c.IsAborted() || status >= http.StatusInternalServerError {
+			return
+		}
+		finished = true
+		config.DB.Model(&models.IdempotencyKey{}).Where("id = ?", record.ID).Updates(map[string]interface{}{
+			"status_code":   status,
+			"content_type":  recorder.Header().Get("Content-Type"),
+			"response_body": recorder.body.Bytes(),
+		})
+	}
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// bufferRequestBody reads the body so it can be hashed and then read again
+// by the handler. It returns the hash of the method, path and body, and a
+// cleanup function to call once the request is done.
+func bufferRequestBody(req *http.Request) (string, func(), error) {
+	cleanup := func() {}
+
+	hash := sha256.New()
+	io.WriteString(hash, req.Method+" "+req.URL.RequestURI()+"\n")
+	if req.Body == nil {
+		return hex.EncodeToString(hash.Sum(nil)), cleanup, nil
+	}
+	body := req.Body
+	defer body.Close()
+
+	var head bytes.Buffer
+	n, err := io.CopyN(&head, body, idempotencyMemoryLimit+1)
+	if err != nil && err != io.EOF {
+		return "", cleanup, err
+	}
+	if n <= idempotencyMemoryLimit {
+		hash.Write(head.Bytes())
+		req.Body = io.NopCloser(bytes.NewReader(head.Bytes()))
+		return hex.EncodeToString(hash.Sum(nil)), cleanup, nil
+	}
+
+	spool, err := os.CreateTemp("", "request-*")
+	if err != nil {
+		return "", cleanup, err
+	}
+	cleanup = func() {
+		spool.Close()
+		os.Remove(spool.Name())
+	}
+	out := io.MultiWriter(spool, hash)
+	if _, err := io.Copy(out, io.MultiReader(&head, body)); err != nil {
+		return "", cleanup, err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return "", cleanup, err
+	}
+	req.Body = io.NopCloser(spool)
+	return hex.EncodeToString(hash.Sum(nil)), cleanup, nil
+}
+
+// responseRecorder keeps a copy of everything written to the client.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
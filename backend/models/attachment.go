@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Attachment is a file uploaded to a task. The contents live in the
+// storage backend under StorageKey.
+type Attachment struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	TaskID      uint      `gorm:"not null;index" json:"task_id"`
+	UserID      uint      `gorm:"not null;index" json:"user_id"` // Uploader; counts against their quota
+	FileName    string    `gorm:"not null" json:"file_name"`
+	ContentType string    `json:"content_type"` // Sniffed from the contents, not taken from the client
+	Size        int64     `gorm:"not null" json:"size"`
+	StorageKey  string    `gorm:"not null;uniqueIndex" json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// StorageQuota limits how much a user may upload.
+type StorageQuota struct {
+	MaxFileSize  int64 `json:"max_file_size"`
+	MaxTotalSize int64 `json:"max_total_size"`
+}
+
+const megabyte = 1 << 20
+
+// PlanStorageQuotas maps User.SubscriptionPlan to its upload limits.
+var PlanStorageQuotas = map[string]StorageQuota{
+	"free":       {MaxFileSize: 5 * megabyte, MaxTotalSize: 50 * megabyte},
+	"pro":        {MaxFileSize: 50 * megabyte, MaxTotalSize: 5 * 1024 * megabyte},
+	"enterprise": {MaxFileSize: 200 * megabyte, MaxTotalSize: 50 * 1024 * megabyte},
+}
+
+// StorageQuotaFor returns the limits for a plan; unknown plans get the
+// free tier.
+func StorageQuotaFor(plan string) StorageQuota {
+	if q, ok := PlanStorageQuotas[plan]; ok {
+		return q
+	}
+	return PlanStorageQuotas["free"]
+}
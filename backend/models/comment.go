@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Comment is a Markdown note on a task. Bodies are stored as written and
+// rendered by the client.
+type Comment struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	TaskID    uint           `gorm:"not null;index" json:"task_id"`
+	UserID    uint           `gorm:"not null" json:"user_id"`
+	User      User           `gorm:"foreignKey:UserID" json:"-"`
+	Body      string         `gorm:"type:text;not null" json:"body"`
+	EditedAt  *time.Time     `json:"edited_at"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// CommentRevision keeps the body a comment had before each edit.
+type CommentRevision struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	CommentID  uint      `gorm:"not null;index" json:"comment_id"`
+	Body       string    `gorm:"type:text;not null" json:"body"`
+	EditedByID uint      `json:"edited_by_id"`
+	CreatedAt  time.Time `json:"created_at"` // when this body was replaced
+}
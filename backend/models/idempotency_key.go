@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// IdempotencyKey remembers the response to a mutating request sent with an
+// Idempotency-Key header, so a retry gets the same answer instead of
+// running again. StatusCode is 0 while the first request is in flight.
+type IdempotencyKey struct {
+	ID           uint   `gorm:"primaryKey"`
+	UserID       uint   `gorm:"not null;uniqueIndex:idx_user_idempotency_key"`
+	Key          string `gorm:"column:idempotency_key;not null;uniqueIndex:idx_user_idempotency_key"`
+	Method       string `gorm:"not null"`
+	Path         string `gorm:"not null"`
+	RequestHash  string `gorm:"not null"` // method, path and body
+	StatusCode   int    `gorm:"not null;default:0"`
+	ContentType  string
+	ResponseBody []byte
+	ExpiresAt    time.Time `gorm:"not null;index"`
+	CreatedAt    time.Time
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// PasswordResetToken is an emailed, single-use link for setting a new
+// password. Only the hash of the token is stored.
+type PasswordResetToken struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	TokenHash string     `gorm:"uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// Scopes a personal access token can be granted.
+const (
+	ScopeReadTasks  = "read:tasks"
+	ScopeWriteTasks = "write:tasks"
+	ScopeBilling    = "billing"
+)
+
+var AccessTokenScopes = []string{ScopeReadTasks, ScopeWriteTasks, ScopeBilling}
+
+// PersonalAccessTokenPrefix starts every personal access token, which
+// tells them apart from JWTs and makes leaked ones easy to search for.
+const PersonalAccessTokenPrefix = "tmpat_"
+
+// PersonalAccessToken is a long-lived, named API token for scripts and CI.
+// It only reaches the routes its scopes allow. The token is shown once
+// when created; only its hash is stored.
+type PersonalAccessToken struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	Name       string     `gorm:"not null" json:"name"`
+	TokenHash  string     `gorm:"uniqueIndex;not null" json:"-"`
+	Hint       string     `json:"hint"` // Last characters of the token, to recognise it by
+	Scopes     []string   `gorm:"serializer:json;type:text" json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"` // Nil never expires
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// HasScope reports whether the token was granted scope.
+func (t PersonalAccessToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
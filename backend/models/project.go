@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Project groups a user's tasks. Archiving hides the project's tasks from
+// the default task listing without deleting anything.
+type Project struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	UserID      uint           `gorm:"not null;index" json:"user_id"`
+	Name        string         `gorm:"not null" json:"name"`
+	Description string         `json:"description"`
+	Color       string         `json:"color"`
+	Archived    bool           `gorm:"default:false" json:"archived"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
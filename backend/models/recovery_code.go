@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// RecoveryCode signs a user in once in place of a TOTP code, for when
+// their authenticator is lost. Only the hash of the code is stored.
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"not null;index" json:"-"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// RefreshToken is one link in a login session's chain of refresh tokens.
+// Every refresh marks the token used and issues the next one in the same
+// family; presenting a used token again means it was stolen, and the whole
+// family is revoked. Only the hash of the token is stored.
+type RefreshToken struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	FamilyID  string     `gorm:"index;not null" json:"family_id"`
+	TokenHash string     `gorm:"uniqueIndex;not null" json:"-"`
+	AccessJTI string     `gorm:"index" json:"-"` // Access token issued alongside
+	ExpiresAt time.Time  `gorm:"index" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// RevokedToken is an access token that must no longer be accepted, by its
+// jti. Rows can be dropped once the token would have expired anyway.
+type RevokedToken struct {
+	JTI       string    `gorm:"primaryKey" json:"jti"`
+	ExpiresAt time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
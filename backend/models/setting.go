@@ -0,0 +1,32 @@
+package models
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Setting is an application-wide option that admins change at runtime.
+type Setting struct {
+	Name      string    `gorm:"primaryKey" json:"name"`
+	Value     string    `gorm:"not null" json:"value"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SettingAdminRequireTwoFactor, when true, keeps admins who haven't turned
+// on two-factor authentication out of the admin API.
+const SettingAdminRequireTwoFactor = "admin_require_2fa"
+
+// BoolSetting reads a true/false setting. Settings never set are false.
+func BoolSetting(db *gorm.DB, name string) (bool, error) {
+	var setting Setting
+	if err := db.Where("name = ?", name).First(&setting).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return strconv.ParseBool(setting.Value)
+}
@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// StripeEvent is a webhook event that has been processed. Stripe delivers
+// events at least once; the primary key makes redeliveries a no-op.
+type StripeEvent struct {
+	ID        string    `gorm:"primaryKey" json:"id"` // evt_...
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// StripePayment is a PaymentIntent that has been turned into credits. The
+// unique PaymentIntentID guarantees a payment is credited once, even if
+// it arrives through more than one event.
+type StripePayment struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	PaymentIntentID string    `gorm:"uniqueIndex;not null" json:"payment_intent_id"`
+	UserID          uint      `gorm:"index" json:"user_id"`
+	Credits         int       `json:"credits"`
+	AmountCents     int64     `json:"amount_cents"`
+	TransactionID   uint      `json:"transaction_id"`
+	RefundedCredits int       `json:"refunded_credits"`
+	DisputedCredits int       `json:"disputed_credits"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+const (
+	ClawbackRefund  = "refund"
+	ClawbackDispute = "dispute"
+)
+
+const (
+	ClawbackSettled       = "settled"        // Taken from the balance in full
+	ClawbackOwed          = "owed"           // Shortfall added to CreditsOwed
+	ClawbackPendingReview = "pending_review" // Shortfall waiting for an admin
+	ClawbackWaived        = "waived"         // Admin let the shortfall go
+	ClawbackReversed      = "reversed"       // Dispute won; credits given back
+)
+
+// Clawback takes back credits from a refunded or disputed payment. When
+// the user has already spent them, Shortfall is what the balance couldn't
+// cover and Status records how the clawback policy dealt with it. Charged
+// is what reached the ledger: Recovered plus any shortfall made owed.
+type Clawback struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	UserID          uint      `gorm:"index" json:"user_id"`
+	PaymentIntentID string    `gorm:"index" json:"payment_intent_id"`
+	Reason          string    `json:"reason"`                  // refund, dispute
+	StripeRef       string    `gorm:"index" json:"stripe_ref"` // Charge or dispute ID
+	Credits         int       `json:"credits"`
+	Recovered       int       `json:"recovered"`
+	Shortfall       int       `json:"shortfall"`
+	Charged         int       `json:"charged"`
+	Status          string    `gorm:"index" json:"status"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
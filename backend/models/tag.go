@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Tag is a user-owned label that can be attached to any of that user's tasks.
+type Tag struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_user_tag_name" json:"user_id"`
+	Name      string    `gorm:"not null;uniqueIndex:idx_user_tag_name" json:"name"`
+	Color     string    `json:"color"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
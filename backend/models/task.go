@@ -6,21 +6,61 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	StatusPending    = "pending"
+	StatusInProgress = "in-progress"
+	StatusCompleted  = "completed"
+
+	PriorityLow    = "low"
+	PriorityMedium = "medium"
+	PriorityHigh   = "high"
+)
+
+var (
+	TaskStatuses   = []string{StatusPending, StatusInProgress, StatusCompleted}
+	TaskPriorities = []string{PriorityLow, PriorityMedium, PriorityHigh}
+)
+
 type Task struct {
-	ID          uint           `gorm:"primaryKey" json:"id"`
-	Title       string         `gorm:"not null" json:"title" binding:"required"`
-	Description string         `json:"description"`
-	Status      string         `gorm:"default:'pending'" json:"status"`  // pending, in-progress, completed
-	Priority    string         `gorm:"default:'medium'" json:"priority"` // low, medium, high
-	DueDate     *time.Time     `json:"due_date"`
-	Assignee    string         `json:"assignee"`
-	UserID      uint           `json:"user_id"`
-	User        User           `gorm:"foreignKey:UserID" json:"-"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	Title        string     `gorm:"not null" json:"title" binding:"required"`
+	Description  string     `json:"description"`
+	Status       string     `gorm:"default:'pending'" json:"status"`  // pending, in-progress, completed; moves are governed by workflow.Tasks
+	Priority     string     `gorm:"default:'medium'" json:"priority"` // low, medium, high
+	DueDate      *time.Time `json:"due_date"`
+	Assignee     string     `json:"assignee"`
+	UserID       uint       `json:"user_id"`
+	User         User       `gorm:"foreignKey:UserID" json:"-"`
+	WorkspaceID  *uint      `gorm:"index" json:"workspace_id"` // nil for personal tasks
+	ProjectID    *uint      `gorm:"index" json:"project_id"`
+	ParentID     *uint      `gorm:"index" json:"parent_id"`
+	Position     int        `gorm:"default:0" json:"position"`        // Order among siblings
+	Progress     *float64   `gorm:"-" json:"progress,omitempty"`      // Share of completed work in the subtree, 0..1
+	CommentCount *int64     `gorm:"-" json:"comment_count,omitempty"` // Only filled in on the task detail
+	CompletedAt  *time.Time `json:"completed_at"`
+	Tags         []Tag      `gorm:"many2many:task_tags;" json:"tags"`
+	// Recurrence: every occurrence carries the series rule and start so the
+	// next one can be generated from any of them.
+	RecurrenceRule    string         `json:"recurrence_rule,omitempty"`
+	RecurrenceStart   *time.Time     `json:"recurrence_start,omitempty"`
+	RecurrenceIndex   int            `gorm:"default:0" json:"recurrence_index,omitempty"` // 1-based position in the series
+	NextOccurrenceID  *uint          `gorm:"index" json:"next_occurrence_id,omitempty"`
+	RecurrenceEnded   bool           `gorm:"default:false" json:"recurrence_ended,omitempty"` // COUNT/UNTIL reached
+	RecurrenceRetryAt *time.Time     `gorm:"index" json:"-"`                                  // Set when the owner couldn't pay for the next occurrence
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 func Migrate(db *gorm.DB) error {
-	return db.AutoMigrate(&User{}, &Task{}, &Transaction{})
+	if err := db.AutoMigrate(&User{}, &Task{}, &Transaction{}, &TaskDependency{}, &Tag{}, &Project{}, &Workspace{}, &WorkspaceMember{}, &WorkspaceInvite{}, &Comment{}, &CommentRevision{}, &Attachment{}, &TaskEvent{}, &IdempotencyKey{}, &StripeEvent{}, &StripePayment{}, &Clawback{}, &RefreshToken{}, &RevokedToken{}, &PasswordResetToken{}, &RecoveryCode{}, &PersonalAccessToken{}, &Setting{}); err != nil {
+		return err
+	}
+	if err := normalizeTaskEnums(db); err != nil {
+		return err
+	}
+	if err := backfillTasksCreated(db); err != nil {
+		return err
+	}
+	return migrateTaskSearch(db)
 }
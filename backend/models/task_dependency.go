@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// TaskDependency records that BlockerID must be completed before TaskID.
+type TaskDependency struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	TaskID    uint      `gorm:"not null;uniqueIndex:idx_task_blocker" json:"task_id"`
+	BlockerID uint      `gorm:"not null;uniqueIndex:idx_task_blocker;index" json:"blocker_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Legacy spellings written before status and priority were validated.
+// Anything not listed here falls back to the column default.
+var (
+	legacyStatuses = map[string]string{
+		"todo":        StatusPending,
+		"open":        StatusPending,
+		"new":         StatusPending,
+		"in_progress": StatusInProgress,
+		"inprogress":  StatusInProgress,
+		"in progress": StatusInProgress,
+		"doing":       StatusInProgress,
+		"started":     StatusInProgress,
+		"done":        StatusCompleted,
+		"complete":    StatusCompleted,
+		"closed":      StatusCompleted,
+	}
+	legacyPriorities = map[string]string{
+		"minor":    PriorityLow,
+		"normal":   PriorityMedium,
+		"major":    PriorityHigh,
+		"urgent":   PriorityHigh,
+		"critical": PriorityHigh,
+	}
+)
+
+// normalizeTaskEnums rewrites task rows whose status or priority is not one
+// of the allowed values, and backfills completed_at for completed tasks.
+func normalizeTaskEnums(db *gorm.DB) error {
+	tx := db.Unscoped().Session(&gorm.Session{SkipHooks: true})
+
+	if err := normalizeColumn(tx, "status", TaskStatuses, legacyStatuses, StatusPending); err != nil {
+		return err
+	}
+	if err := normalizeColumn(tx, "priority", TaskPriorities, legacyPriorities, PriorityMedium); err != nil {
+		return err
+	}
+
+	return tx.Model(&Task{}).
+		Where("status = ? AND completed_at IS NULL", StatusCompleted).
+		UpdateColumn("completed_at", gorm.Expr("COALESCE(updated_at, created_at, CURRENT_TIMESTAMP)")).Error
+}
+
+func normalizeColumn(tx *gorm.DB, column string, allowed []string, legacy map[string]string, fallback string) error {
+	var bad []string
+	if err := tx.Model(&Task{}).
+		Where(column+" NOT IN ? OR "+column+" IS NULL", allowed).
+		Distinct().Pluck("COALESCE("+column+", '')", &bad).Error; err != nil {
+		return err
+	}
+
+	for _, value := range bad {
+		target := fallback
+		clean := strings.ToLower(strings.TrimSpace(value))
+		if mapped, ok := legacy[clean]; ok {
+			target = mapped
+		}
+		for _, a := range allowed {
+			if clean == a {
+				target = a
+			}
+		}
+		if err := tx.Model(&Task{}).
+			Where("COALESCE("+column+", '') = ?", value).
+			UpdateColumn(column, target).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Task event actions.
+const (
+	TaskEventCreated  = "created"
+	TaskEventUpdated  = "updated"
+	TaskEventDeleted  = "deleted"
+	TaskEventReverted = "reverted"
+	TaskEventRestored = "restored"
+)
+
+// FieldChange is a field's JSON value before and after an event. From is
+// null for created events.
+type FieldChange struct {
+	From json.RawMessage `json:"from"`
+	To   json.RawMessage `json:"to"`
+}
+
+// TaskEvent is one entry in a task's activity history.
+type TaskEvent struct {
+	ID        uint                   `gorm:"primaryKey" json:"id"`
+	TaskID    uint                   `gorm:"not null;index" json:"task_id"`
+	ActorID   uint                   `json:"actor_id"`
+	Action    string                 `gorm:"not null" json:"action"`
+	Changes   map[string]FieldChange `gorm:"serializer:json;type:text" json:"changes"`
+	CreatedAt time.Time              `json:"created_at"`
+}
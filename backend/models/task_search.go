@@ -0,0 +1,65 @@
+package models
+
+import "gorm.io/gorm"
+
+// migrateTaskSearch creates the full-text index used by the search package.
+// Postgres gets a weighted tsvector column with a GIN index; SQLite gets an
+// FTS5 table kept in sync with tasks through triggers.
+func migrateTaskSearch(db *gorm.DB) error {
+	switch db.Dialector.Name() {
+	case "postgres":
+		return migratePostgresTaskSearch(db)
+	case "sqlite":
+		return migrateSQLiteTaskSearch(db)
+	}
+	return nil
+}
+
+func migratePostgresTaskSearch(db *gorm.DB) error {
+	statements := []string{
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS search_vector tsvector
+			GENERATED ALWAYS AS (
+				setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+				setweight(to_tsvector('english', coalesce(description, '')), 'B')
+			) STORED`,
+		`CREATE INDEX IF NOT EXISTS idx_tasks_search_vector ON tasks USING GIN (search_vector)`,
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateSQLiteTaskSearch(db *gorm.DB) error {
+	var existing int64
+	if err := db.Raw("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'tasks_fts'").Scan(&existing).Error; err != nil {
+		return err
+	}
+
+	statements := []string{
+		`CREATE VIRTUAL TABLE IF NOT EXISTS tasks_fts USING fts5(title, description, content='tasks', content_rowid='id')`,
+		`CREATE TRIGGER IF NOT EXISTS tasks_fts_ai AFTER INSERT ON tasks BEGIN
+			INSERT INTO tasks_fts(rowid, title, description) VALUES (new.id, new.title, new.description);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS tasks_fts_ad AFTER DELETE ON tasks BEGIN
+			INSERT INTO tasks_fts(tasks_fts, rowid, title, description) VALUES ('delete', old.id, old.title, old.description);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS tasks_fts_au AFTER UPDATE ON tasks BEGIN
+			INSERT INTO tasks_fts(tasks_fts, rowid, title, description) VALUES ('delete', old.id, old.title, old.description);
+			INSERT INTO tasks_fts(rowid, title, description) VALUES (new.id, new.title, new.description);
+		END`,
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+
+	// Index rows that were written before the FTS table existed.
+	if existing == 0 {
+		return db.Exec(`INSERT INTO tasks_fts(tasks_fts) VALUES ('rebuild')`).Error
+	}
+	return nil
+}
@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type User struct {
 	ID                    uint       `gorm:"primaryKey" json:"id"`
@@ -9,11 +13,32 @@ type User struct {
 	Password              string     `json:"-"` // Don't return password in JSON
 	Role                  string     `gorm:"default:'user'" json:"role"`
 	Verified              bool       `gorm:"default:false" json:"verified"`
+	VerificationSentAt    *time.Time `json:"-"`
+	TwoFactorEnabled      bool       `gorm:"default:false" json:"two_factor_enabled"`
+	TwoFactorSecret       string     `json:"-"` // Base32 TOTP secret; set while enrolling, kept once enabled
+	TwoFactorLastStep     int64      `json:"-"` // Last TOTP time step accepted, so a code can't be replayed
+	TwoFactorFailures     int        `gorm:"default:0" json:"-"`
+	TwoFactorLockedUntil  *time.Time `json:"-"`
 	SubscriptionPlan      string     `gorm:"default:'free'" json:"subscription_plan"`
 	SubscriptionStatus    string     `gorm:"default:'active'" json:"subscription_status"`
 	StripeCustomerID      string     `json:"stripe_customer_id"`
 	SubscriptionExpiresAt *time.Time `json:"subscription_expires_at"`
-	Credits               int        `gorm:"default:5" json:"credits"` // New field
+	Credits               int        `gorm:"default:5;check:chk_users_credits,credits >= 0" json:"credits"`
+	CreditsOwed           int        `gorm:"default:0;check:chk_users_credits_owed,credits_owed >= 0" json:"credits_owed"` // Clawed back after being spent; repaid from future credits
+	CreditHold            bool       `gorm:"default:false" json:"credit_hold"`                                             // Blocks spending until CreditsOwed is repaid
+	TasksCreated          int        `gorm:"default:0" json:"-"`                                                           // Every task ever created, for UnverifiedTaskLimit
 	CreatedAt             time.Time  `json:"created_at"`
 	UpdatedAt             time.Time  `json:"updated_at"`
 }
+
+// UnverifiedTaskLimit caps how many tasks a user can create before
+// verifying their email address.
+const UnverifiedTaskLimit = 10
+
+// backfillTasksCreated counts the tasks of users from before TasksCreated
+// was tracked, trashed ones included.
+func backfillTasksCreated(db *gorm.DB) error {
+	return db.Model(&User{}).
+		Where("tasks_created = 0").
+		UpdateColumn("tasks_created", gorm.Expr("(SELECT COUNT(*) FROM tasks WHERE tasks.user_id = users.id)")).Error
+}
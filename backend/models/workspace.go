@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	WorkspaceRoleOwner  = "owner"
+	WorkspaceRoleEditor = "editor"
+	WorkspaceRoleViewer = "viewer"
+)
+
+// Workspace is a shared space whose tasks are visible to all of its members.
+type Workspace struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	Name      string         `gorm:"not null" json:"name"`
+	OwnerID   uint           `gorm:"not null;index" json:"owner_id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+type WorkspaceMember struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	WorkspaceID uint      `gorm:"not null;uniqueIndex:idx_workspace_member" json:"workspace_id"`
+	UserID      uint      `gorm:"not null;uniqueIndex:idx_workspace_member;index" json:"user_id"`
+	Role        string    `gorm:"not null" json:"role"` // owner, editor, viewer
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// WorkspaceInvite lets whoever holds the token join the workspace, provided
+// they are signed in with the invited email. Only the token hash is stored.
+type WorkspaceInvite struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	WorkspaceID uint       `gorm:"not null;index" json:"workspace_id"`
+	Email       string     `gorm:"not null" json:"email"`
+	Role        string     `gorm:"not null" json:"role"`
+	TokenHash   string     `gorm:"uniqueIndex;not null" json:"-"`
+	InvitedByID uint       `json:"invited_by_id"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	AcceptedAt  *time.Time `json:"accepted_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// WorkspaceWriteRoles may create, change and delete workspace tasks.
+var WorkspaceWriteRoles = []string{WorkspaceRoleOwner, WorkspaceRoleEditor}
+
+// WorkspaceReadRoles may view workspace tasks.
+var WorkspaceReadRoles = []string{WorkspaceRoleOwner, WorkspaceRoleEditor, WorkspaceRoleViewer}
+
+// TasksAccessibleBy limits a task query to personal tasks the user created
+// plus tasks in workspaces where the user holds one of roles.
+func TasksAccessibleBy(userID interface{}, roles []string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		memberships := db.Session(&gorm.Session{NewDB: true}).
+			Model(&WorkspaceMember{}).
+			Select("workspace_id").
+			Where("user_id = ? AND role IN ?", userID, roles)
+		return db.Where("(tasks.workspace_id IS NULL AND tasks.user_id = ?) OR tasks.workspace_id IN (?)", userID, memberships)
+	}
+}
@@ -0,0 +1,335 @@
+// Package recurrence implements the subset of iCalendar RRULEs (RFC 5545)
+// that recurring tasks support, and spawns the next task of a series.
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+)
+
+// maxIterations stops a rule that never matches from spinning forever,
+// e.g. BYMONTHDAY=31 combined with INTERVAL=12 starting in February.
+const maxIterations = 100000
+
+// WeekdayNum is a BYDAY entry such as MO, 2TU or -1FR. N is zero when the
+// entry applies to every matching weekday in the period.
+type WeekdayNum struct {
+	Day time.Weekday
+	N   int
+}
+
+// Rule is a parsed RRULE.
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	Count      int
+	Until      *time.Time
+}
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// Parse reads a rule such as "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10". An
+// optional "RRULE:" prefix is accepted.
+func Parse(raw string) (*Rule, error) {
+	raw = strings.TrimPrefix(strings.TrimSpace(raw), "RRULE:")
+	if raw == "" {
+		return nil, errors.New("empty recurrence rule")
+	}
+
+	r := &Rule{Interval: 1}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(raw, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("malformed rule part %q", part)
+		}
+		key = strings.ToUpper(key)
+		value = strings.ToUpper(value)
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate rule part %s", key)
+		}
+		seen[key] = true
+
+		switch key {
+		case "FREQ":
+			switch Frequency(value) {
+			case Daily, Weekly, Monthly:
+				r.Freq = Frequency(value)
+			default:
+				return nil, fmt.Errorf("unsupported FREQ %s", value)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, errors.New("INTERVAL must be a positive integer")
+			}
+			r.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, errors.New("COUNT must be a positive integer")
+			}
+			r.Count = n
+		case "UNTIL":
+			until, err := parseUntil(value)
+			if err != nil {
+				return nil, err
+			}
+			r.Until = &until
+		case "BYDAY":
+			for _, code := range strings.Split(value, ",") {
+				wd, err := parseWeekdayNum(code)
+				if err != nil {
+					return nil, err
+				}
+				r.ByDay = append(r.ByDay, wd)
+			}
+		case "BYMONTHDAY":
+			for _, code := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(code)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return nil, fmt.Errorf("invalid BYMONTHDAY %q", code)
+				}
+				r.ByMonthDay = append(r.ByMonthDay, n)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported rule part %s", key)
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, errors.New("FREQ is required")
+	}
+	if r.Count > 0 && r.Until != nil {
+		return nil, errors.New("COUNT and UNTIL cannot both be set")
+	}
+	if len(r.ByMonthDay) > 0 && r.Freq != Monthly {
+		return nil, errors.New("BYMONTHDAY is only supported with FREQ=MONTHLY")
+	}
+	for _, wd := range r.ByDay {
+		if wd.N != 0 && r.Freq != Monthly {
+			return nil, errors.New("numbered BYDAY entries are only supported with FREQ=MONTHLY")
+		}
+	}
+	return r, nil
+}
+
+func parseUntil(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			if layout == "20060102" {
+				// A date-only UNTIL includes the whole day.
+				t = t.Add(24*time.Hour - time.Second)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid UNTIL %q", value)
+}
+
+func parseWeekdayNum(code string) (WeekdayNum, error) {
+	if len(code) < 2 {
+		return WeekdayNum{}, fmt.Errorf("invalid BYDAY %q", code)
+	}
+	day, ok := weekdayCodes[code[len(code)-2:]]
+	if !ok {
+		return WeekdayNum{}, fmt.Errorf("invalid BYDAY %q", code)
+	}
+	wd := WeekdayNum{Day: day}
+	if prefix := code[:len(code)-2]; prefix != "" {
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -5 || n > 5 {
+			return WeekdayNum{}, fmt.Errorf("invalid BYDAY %q", code)
+		}
+		wd.N = n
+	}
+	return wd, nil
+}
+
+// String renders the rule back in RRULE syntax.
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		codes := make([]string, len(r.ByDay))
+		for i, wd := range r.ByDay {
+			code := strings.ToUpper(wd.Day.String()[:2])
+			if wd.N != 0 {
+				code = strconv.Itoa(wd.N) + code
+			}
+			codes[i] = code
+		}
+		parts = append(parts, "BYDAY="+strings.Join(codes, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, d := range r.ByMonthDay {
+			days[i] = strconv.Itoa(d)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Next returns the first occurrence strictly after `after` for a series
+// that started at start, together with its 1-based position in the series.
+// start itself is always occurrence 1. ok is false once COUNT or UNTIL
+// ends the series.
+func (r *Rule) Next(start, after time.Time) (index int, next time.Time, ok bool) {
+	index = 1
+	if start.After(after) {
+		return 1, start, true
+	}
+
+	iterations := 0
+	for period := 0; iterations < maxIterations; period++ {
+		candidates := r.candidates(start, period)
+		for _, t := range candidates {
+			iterations++
+			if !t.After(start) {
+				continue
+			}
+			if r.Until != nil && t.After(*r.Until) {
+				return 0, time.Time{}, false
+			}
+			index++
+			if r.Count > 0 && index > r.Count {
+				return 0, time.Time{}, false
+			}
+			if t.After(after) {
+				return index, t, true
+			}
+		}
+		if len(candidates) == 0 {
+			iterations++
+		}
+	}
+	return 0, time.Time{}, false
+}
+
+// candidates lists the occurrences inside the period-th interval after
+// start, in chronological order. Times of day always follow start.
+func (r *Rule) candidates(start time.Time, period int) []time.Time {
+	at := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
+	}
+
+	var out []time.Time
+	switch r.Freq {
+	case Daily:
+		t := start.AddDate(0, 0, period*r.Interval)
+		if r.matchesWeekday(t.Weekday()) {
+			out = append(out, t)
+		}
+
+	case Weekly:
+		// Weeks start on Monday (the RFC 5545 default WKST).
+		offset := (int(start.Weekday()) + 6) % 7
+		monday := start.AddDate(0, 0, -offset+period*7*r.Interval)
+		for i := 0; i < 7; i++ {
+			t := monday.AddDate(0, 0, i)
+			if len(r.ByDay) == 0 {
+				if t.Weekday() == start.Weekday() {
+					out = append(out, t)
+				}
+			} else if r.matchesWeekday(t.Weekday()) {
+				out = append(out, t)
+			}
+		}
+
+	case Monthly:
+		first := time.Date(start.Year(), start.Month()+time.Month(period*r.Interval), 1, 0, 0, 0, 0, start.Location())
+		y, m := first.Year(), first.Month()
+		daysIn := time.Date(y, m+1, 0, 0, 0, 0, 0, start.Location()).Day()
+
+		days := map[int]bool{}
+		for _, md := range r.ByMonthDay {
+			d := md
+			if md < 0 {
+				d = daysIn + md + 1
+			}
+			if d >= 1 && d <= daysIn {
+				days[d] = true
+			}
+		}
+		for _, wd := range r.ByDay {
+			for _, d := range weekdaysInMonth(y, m, daysIn, wd) {
+				days[d] = true
+			}
+		}
+		if len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 && start.Day() <= daysIn {
+			days[start.Day()] = true
+		}
+
+		sorted := make([]int, 0, len(days))
+		for d := range days {
+			sorted = append(sorted, d)
+		}
+		sort.Ints(sorted)
+		for _, d := range sorted {
+			out = append(out, at(y, m, d))
+		}
+	}
+	return out
+}
+
+func (r *Rule) matchesWeekday(day time.Weekday) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, wd := range r.ByDay {
+		if wd.Day == day {
+			return true
+		}
+	}
+	return false
+}
+
+// weekdaysInMonth returns the days of month m that match wd, e.g. every
+// Tuesday, or only the second (2TU) or last (-1TU) one.
+func weekdaysInMonth(y int, m time.Month, daysIn int, wd WeekdayNum) []int {
+	var matches []int
+	firstWeekday := time.Date(y, m, 1, 0, 0, 0, 0, time.UTC).Weekday()
+	for d := 1 + (int(wd.Day)-int(firstWeekday)+7)%7; d <= daysIn; d += 7 {
+		matches = append(matches, d)
+	}
+	switch {
+	case wd.N == 0:
+		return matches
+	case wd.N > 0 && wd.N <= len(matches):
+		return []int{matches[wd.N-1]}
+	case wd.N < 0 && -wd.N <= len(matches):
+		return []int{matches[len(matches)+wd.N]}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package recurrence
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func occurrences(t *testing.T, raw string, start time.Time, n int) []string {
+	rule, err := Parse(raw)
+	assert.NoError(t, err)
+
+	var out []string
+	after := start.Add(-time.Second)
+	for i := 0; i < n; i++ {
+		index, next, ok := rule.Next(start, after)
+		if !ok {
+			break
+		}
+		assert.Equal(t, i+1, index)
+		out = append(out, next.Format("2006-01-02"))
+		after = next
+	}
+	return out
+}
+
+func TestWeeklyByDayWithCount(t *testing.T) {
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC) // Monday
+	got := occurrences(t, "RRULE:FREQ=WEEKLY;BYDAY=MO,TH;COUNT=5", start, 10)
+	assert.Equal(t, []string{"2026-03-02", "2026-03-05", "2026-03-09", "2026-03-12", "2026-03-16"}, got)
+}
+
+func TestMonthlyNthWeekdayWithUntil(t *testing.T) {
+	start := time.Date(2026, 1, 13, 9, 0, 0, 0, time.UTC) // second Tuesday
+	got := occurrences(t, "FREQ=MONTHLY;BYDAY=2TU;UNTIL=20260430", start, 10)
+	assert.Equal(t, []string{"2026-01-13", "2026-02-10", "2026-03-10", "2026-04-14"}, got)
+
+	got = occurrences(t, "FREQ=MONTHLY;BYDAY=-1FR;COUNT=3", time.Date(2026, 1, 30, 0, 0, 0, 0, time.UTC), 10)
+	assert.Equal(t, []string{"2026-01-30", "2026-02-27", "2026-03-27"}, got)
+}
+
+func TestDailyIntervalSkipsMissedOccurrences(t *testing.T) {
+	rule, err := Parse("FREQ=DAILY;INTERVAL=2")
+	assert.NoError(t, err)
+
+	start := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+	index, next, ok := rule.Next(start, time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC))
+	assert.True(t, ok)
+	assert.Equal(t, 6, index)
+	assert.Equal(t, time.Date(2026, 1, 11, 8, 0, 0, 0, time.UTC), next)
+}
+
+func TestParseRejectsUnsupportedRules(t *testing.T) {
+	for _, raw := range []string{"", "FREQ=HOURLY", "FREQ=DAILY;COUNT=0", "FREQ=WEEKLY;BYDAY=2MO", "FREQ=DAILY;COUNT=2;UNTIL=20260101", "INTERVAL=2"} {
+		_, err := Parse(raw)
+		assert.Error(t, err, raw)
+	}
+}
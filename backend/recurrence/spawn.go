@@ -0,0 +1,136 @@
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"taskmanager-backend/backend/history"
+	"taskmanager-backend/backend/ledger"
+	"taskmanager-backend/backend/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OccurrenceCost is what each generated occurrence costs its owner. The
+// first task of a series is charged by CreateTask like any other task;
+// every later occurrence is charged when it is generated. If the owner
+// can't pay, nothing is generated and the scheduler tries again after
+// PaymentRetryInterval, so a top-up resumes the series without skipping
+// the rule.
+const OccurrenceCost = 1
+
+// PaymentRetryInterval is how long the scheduler leaves a series alone
+// after its owner couldn't pay for the next occurrence.
+const PaymentRetryInterval = time.Hour
+
+var errAlreadySpawned = errors.New("next occurrence already generated")
+
+// SpawnNext generates the occurrence that follows task, unless one was
+// already generated or the series has ended. Missed occurrences are not
+// back-filled: the next one is the first scheduled after both the task's
+// due date and now. It returns the new task, or nil if nothing was created.
+func SpawnNext(db *gorm.DB, task models.Task, now time.Time) (*models.Task, error) {
+	if task.RecurrenceRule == "" || task.RecurrenceStart == nil || task.DueDate == nil ||
+		task.NextOccurrenceID != nil || task.RecurrenceEnded {
+		return nil, nil
+	}
+
+	rule, err := Parse(task.RecurrenceRule)
+	if err != nil {
+		return nil, err
+	}
+
+	after := *task.DueDate
+	if now.After(after) {
+		after = now
+	}
+	index, due, ok := rule.Next(*task.RecurrenceStart, after)
+	if !ok {
+		return nil, db.Model(&models.Task{}).Where("id = ?", task.ID).Update("recurrence_ended", true).Error
+	}
+
+	next := models.Task{
+		Title:           task.Title,
+		Description:     task.Description,
+		Status:          models.StatusPending,
+		Priority:        task.Priority,
+		DueDate:         &due,
+		Assignee:        task.Assignee,
+		UserID:          task.UserID,
+		WorkspaceID:     task.WorkspaceID,
+		ProjectID:       task.ProjectID,
+		ParentID:        task.ParentID,
+		Position:        task.Position,
+		RecurrenceRule:  task.RecurrenceRule,
+		RecurrenceStart: task.RecurrenceStart,
+		RecurrenceIndex: index,
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if _, err := ledger.Debit(tx, task.UserID, OccurrenceCost, "usage", fmt.Sprintf("Recurring task: %s (#%d)", task.Title, index)); err != nil {
+			return err
+		}
+		if err := tx.Create(&next).Error; err != nil {
+			return err
+		}
+		if err := history.Record(tx, next.ID, task.UserID, models.TaskEventCreated, history.Diff(nil, history.Snapshot(next))); err != nil {
+			return err
+		}
+		if err := tx.Exec("INSERT INTO task_tags (task_id, tag_id) SELECT ?, tag_id FROM task_tags WHERE task_id = ?", next.ID, task.ID).Error; err != nil {
+			return err
+		}
+
+		// Claim the link last; if another worker got here first, undo.
+		claim := tx.Model(&models.Task{}).
+			Where("id = ? AND next_occurrence_id IS NULL", task.ID).
+			Update("next_occurrence_id", next.ID)
+		if claim.Error != nil {
+			return claim.Error
+		}
+		if claim.RowsAffected == 0 {
+			return errAlreadySpawned
+		}
+		return nil
+	})
+
+	if errors.Is(err, errAlreadySpawned) {
+		return nil, nil
+	}
+	if errors.Is(err, ledger.ErrInsufficientCredits) || errors.Is(err, ledger.ErrCreditHold) {
+		retryAt := now.Add(PaymentRetryInterval)
+		if backoff := db.Model(&models.Task{}).Where("id = ?", task.ID).Update("recurrence_retry_at", retryAt).Error; backoff != nil {
+			return nil, backoff
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &next, nil
+}
+
+// SpawnDue generates the next occurrence for every recurring task whose
+// due date has arrived. Failures are collected per task so one bad row or
+// an empty balance doesn't hold up the rest; series whose owner couldn't
+// pay are skipped until their retry time.
+func SpawnDue(db *gorm.DB, now time.Time) (spawned int, errs []error) {
+	var due []models.Task
+	err := db.Where("recurrence_rule <> '' AND next_occurrence_id IS NULL AND recurrence_ended = ?", false).
+		Where("due_date <= ?", now).
+		Where("recurrence_retry_at IS NULL OR recurrence_retry_at <= ?", now).
+		Find(&due).Error
+	if err != nil {
+		return 0, []error{err}
+	}
+
+	for _, task := range due {
+		next, err := SpawnNext(db, task, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("task %d: %w", task.ID, err))
+			continue
+		}
+		if next != nil {
+			spawned++
+		}
+	}
+	return spawned, errs
+}
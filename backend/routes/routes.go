@@ -5,6 +5,7 @@ import (
 	"strings"
 	"taskmanager-backend/backend/handlers"
 	"taskmanager-backend/backend/middlewares"
+	"taskmanager-backend/backend/models"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -22,8 +23,8 @@ func SetupRouter() *gin.Engine {
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     allowedOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "If-Match", "If-None-Match", "Idempotency-Key"},
+		ExposeHeaders:    []string{"Content-Length", "Content-Disposition", "ETag", "Idempotent-Replayed"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
@@ -39,36 +40,127 @@ func SetupRouter() *gin.Engine {
 	{
 		auth.POST("/signup", handlers.Register)
 		auth.POST("/login", handlers.Login)
+		auth.POST("/login/2fa", handlers.LoginTwoFactor)
+		auth.POST("/refresh", handlers.RefreshSession)
+		auth.POST("/verify-email", handlers.VerifyEmail)
+		auth.POST("/forgot-password", handlers.ForgotPassword)
+		auth.POST("/reset-password", handlers.ResetPassword)
 	}
 
 	// Stripe Webhook (No Auth Middleware)
 	api.POST("/webhook", handlers.HandleStripeWebhook)
 
-	// Protected routes
+	// Protected routes. Logins reach all of them; personal access tokens
+	// only reach the routes in a group for one of their scopes.
 	protected := api.Group("/")
 	protected.Use(middlewares.JwtAuthMiddleware())
 	{
 		protected.GET("/auth/me", handlers.CurrentUser)
-		protected.PUT("/auth/profile", handlers.UpdateProfile)
+	}
+
+	// Account and workspace management takes a login.
+	account := protected.Group("", middlewares.SessionOnly())
+	{
+		account.POST("/auth/logout", handlers.Logout)
+		account.POST("/auth/logout-all", handlers.LogoutEverywhere)
+		account.POST("/auth/verify-email/resend", handlers.ResendVerification)
+		account.PUT("/auth/profile", handlers.UpdateProfile)
+		account.POST("/auth/2fa/enroll", handlers.EnrollTwoFactor)
+		account.POST("/auth/2fa/confirm", handlers.ConfirmTwoFactor)
+		account.POST("/auth/2fa/disable", handlers.DisableTwoFactor)
+		account.POST("/auth/2fa/recovery-codes", handlers.RegenerateRecoveryCodes)
+		account.GET("/auth/tokens", handlers.GetAccessTokens)
+		account.POST("/auth/tokens", handlers.CreateAccessToken)
+		account.DELETE("/auth/tokens/:id", handlers.DeleteAccessToken)
+
+		account.POST("/workspaces", handlers.CreateWorkspace)
+		account.PUT("/workspaces/:id", handlers.UpdateWorkspace)
+		account.DELETE("/workspaces/:id", handlers.DeleteWorkspace)
+		account.PUT("/workspaces/:id/members/:user_id", handlers.UpdateWorkspaceMember)
+		account.DELETE("/workspaces/:id/members/:user_id", handlers.RemoveWorkspaceMember)
+		account.POST("/workspaces/:id/transfer", handlers.TransferWorkspace)
+		account.POST("/workspaces/:id/invites", handlers.CreateWorkspaceInvite)
+
+		account.POST("/invites/accept", handlers.AcceptWorkspaceInvite)
+	}
+
+	// Only billing and task writes honour Idempotency-Key: account and
+	// admin responses can hold tokens and secrets that mustn't be stored.
+	billing := protected.Group("", middlewares.RequireScope(models.ScopeBilling), middlewares.IdempotencyMiddleware())
+	{
+		billing.POST("/subscriptions/purchase", handlers.CreatePaymentIntent)
+	}
+
+	readTasks := protected.Group("", middlewares.RequireScope(models.ScopeReadTasks))
+	{
+		readTasks.GET("/tasks", handlers.GetTasks)
+		readTasks.GET("/tasks/search", handlers.SearchTasks)
+		readTasks.GET("/tasks/trash", handlers.GetTrash)
+		readTasks.GET("/tasks/ready", handlers.GetTaskPlan)
+		readTasks.GET("/tasks/:id", handlers.GetTask)
+		readTasks.GET("/tasks/:id/children", handlers.GetSubtasks)
+		readTasks.GET("/tasks/:id/dependencies", handlers.GetTaskDependencies)
+		readTasks.GET("/tasks/:id/comments", handlers.GetComments)
+		readTasks.GET("/tasks/:id/comments/:comment_id/history", handlers.GetCommentHistory)
+		readTasks.GET("/tasks/:id/attachments", handlers.GetAttachments)
+		readTasks.GET("/tasks/:id/attachments/:attachment_id", handlers.DownloadAttachment)
+		readTasks.GET("/tasks/:id/history", handlers.GetTaskHistory)
+
+		readTasks.GET("/tags", handlers.GetTags)
+
+		readTasks.GET("/projects", handlers.GetProjects)
+		readTasks.GET("/projects/:id", handlers.GetProject)
+		readTasks.GET("/projects/:id/tasks", handlers.GetProjectTasks)
+		readTasks.GET("/projects/:id/stats", handlers.GetProjectStats)
+
+		readTasks.GET("/workspaces", handlers.GetWorkspaces)
+		readTasks.GET("/workspaces/:id", handlers.GetWorkspace)
+	}
+
+	writeTasks := protected.Group("", middlewares.RequireScope(models.ScopeWriteTasks), middlewares.IdempotencyMiddleware())
+	{
+		writeTasks.POST("/tasks", handlers.CreateTask)
+		writeTasks.POST("/tasks/bulk", handlers.BulkTasks)
+		writeTasks.POST("/tasks/:id/restore", handlers.RestoreTask)
+		writeTasks.DELETE("/tasks/trash/:id", handlers.PurgeTask)
+		writeTasks.PUT("/tasks/:id", handlers.UpdateTask)
+		writeTasks.PATCH("/tasks/:id", handlers.PatchTask)
+		writeTasks.DELETE("/tasks/:id", handlers.DeleteTask)
+		writeTasks.PUT("/tasks/:id/parent", handlers.ReparentTask)
+		writeTasks.PUT("/tasks/:id/children/order", handlers.ReorderSubtasks)
+		writeTasks.POST("/tasks/:id/dependencies", handlers.AddTaskDependency)
+		writeTasks.DELETE("/tasks/:id/dependencies/:blocker_id", handlers.RemoveTaskDependency)
+		writeTasks.POST("/tasks/:id/tags", handlers.AttachTaskTags)
+		writeTasks.DELETE("/tasks/:id/tags/:tag_id", handlers.DetachTaskTag)
+		writeTasks.POST("/tasks/:id/comments", handlers.CreateComment)
+		writeTasks.PUT("/tasks/:id/comments/:comment_id", handlers.UpdateComment)
+		writeTasks.DELETE("/tasks/:id/comments/:comment_id", handlers.DeleteComment)
+		writeTasks.POST("/tasks/:id/attachments", handlers.UploadAttachment)
+		writeTasks.DELETE("/tasks/:id/attachments/:attachment_id", handlers.DeleteAttachment)
+		writeTasks.POST("/tasks/:id/history/:event_id/revert", handlers.RevertTask)
 
-		protected.POST("/subscriptions/purchase", handlers.CreatePaymentIntent)
+		writeTasks.POST("/tags", handlers.CreateTag)
+		writeTasks.PUT("/tags/:id", handlers.UpdateTag)
+		writeTasks.DELETE("/tags/:id", handlers.DeleteTag)
 
-		protected.GET("/tasks", handlers.GetTasks)
-		protected.POST("/tasks", handlers.CreateTask)
-		protected.GET("/tasks/:id", handlers.GetTask)
-		protected.PUT("/tasks/:id", handlers.UpdateTask)
-		protected.DELETE("/tasks/:id", handlers.DeleteTask)
+		writeTasks.POST("/projects", handlers.CreateProject)
+		writeTasks.PUT("/projects/:id", handlers.UpdateProject)
+		writeTasks.DELETE("/projects/:id", handlers.DeleteProject)
 	}
 
 	// Admin routes
 	admin := api.Group("/admin")
-	admin.Use(middlewares.JwtAuthMiddleware(), middlewares.AdminAuthMiddleware())
+	admin.Use(middlewares.JwtAuthMiddleware(), middlewares.SessionOnly(), middlewares.AdminAuthMiddleware())
 	{
 		admin.GET("/users", handlers.GetAllUsers)
 		admin.GET("/stats", handlers.GetAdminStats)
 		admin.PUT("/users/:id", handlers.UpdateUserStatus)
 		admin.POST("/users/:id/credits", handlers.AddUserCredits)
 		admin.GET("/transactions", handlers.GetAllTransactions)
+		admin.GET("/clawbacks", handlers.GetClawbacks)
+		admin.POST("/clawbacks/:id/resolve", handlers.ResolveClawback)
+		admin.GET("/settings", handlers.GetAdminSettings)
+		admin.PUT("/settings", handlers.UpdateAdminSettings)
 	}
 
 	// Serve Admin UI
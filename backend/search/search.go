@@ -0,0 +1,87 @@
+package search
+
+import (
+	"strings"
+	"taskmanager-backend/backend/models"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// TaskResult is a matching task together with its relevance score.
+// Higher ranks are better on every backend.
+type TaskResult struct {
+	models.Task
+	Rank float64 `json:"rank"`
+}
+
+// TaskSearcher ranks the tasks a user can see against a free-text query
+// over the title and description columns.
+type TaskSearcher interface {
+	Search(db *gorm.DB, userID uint, query string, limit int) ([]TaskResult, error)
+}
+
+// ForDB returns the searcher backed by the full-text index that
+// models.Migrate created for db's driver.
+func ForDB(db *gorm.DB) TaskSearcher {
+	if db.Dialector.Name() == "postgres" {
+		return postgresSearcher{}
+	}
+	return sqliteSearcher{}
+}
+
+// terms splits a raw query into plain words, dropping any operator syntax
+// so user input can never change the shape of the full-text query.
+func terms(query string) []string {
+	return strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+type postgresSearcher struct{}
+
+func (postgresSearcher) Search(db *gorm.DB, userID uint, query string, limit int) ([]TaskResult, error) {
+	words := terms(query)
+	if len(words) == 0 {
+		return []TaskResult{}, nil
+	}
+
+	var results []TaskResult
+	err := db.Model(&models.Task{}).
+		Select("tasks.*, ts_rank(tasks.search_vector, plainto_tsquery('english', ?)) AS rank", strings.Join(words, " ")).
+		Scopes(models.TasksAccessibleBy(userID, models.WorkspaceReadRoles)).
+		Where("tasks.search_vector @@ plainto_tsquery('english', ?)", strings.Join(words, " ")).
+		Order("rank DESC").
+		Order("tasks.id").
+		Limit(limit).
+		Scan(&results).Error
+	return results, err
+}
+
+type sqliteSearcher struct{}
+
+func (sqliteSearcher) Search(db *gorm.DB, userID uint, query string, limit int) ([]TaskResult, error) {
+	words := terms(query)
+	if len(words) == 0 {
+		return []TaskResult{}, nil
+	}
+
+	// Quote every word so FTS5 treats it as a literal term; the terms are
+	// implicitly ANDed, matching plainto_tsquery on Postgres.
+	quoted := make([]string, len(words))
+	for i, w := range words {
+		quoted[i] = `"` + w + `"`
+	}
+
+	var results []TaskResult
+	err := db.Model(&models.Task{}).
+		Select("tasks.*, -bm25(tasks_fts, 10.0, 1.0) AS rank").
+		Joins("JOIN tasks_fts ON tasks_fts.rowid = tasks.id").
+		Where("tasks_fts MATCH ?", strings.Join(quoted, " ")).
+		Scopes(models.TasksAccessibleBy(userID, models.WorkspaceReadRoles)).
+		Order("rank DESC").
+		Order("tasks.id").
+		Limit(limit).
+		Scan(&results).Error
+	return results, err
+}
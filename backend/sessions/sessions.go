@@ -0,0 +1,157 @@
+// Package sessions issues and revokes the tokens behind a login: a
+// short-lived access JWT and a rotating refresh token. All the tokens that
+// descend from one login share a family ID, which access tokens carry as
+// their "sid" claim.
+package sessions
+
+import (
+	"errors"
+	"taskmanager-backend/backend/models"
+	"taskmanager-backend/backend/utils"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+)
+
+// Tokens is what a client receives when it logs in or refreshes.
+type Tokens struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"` // Seconds until AccessToken expires
+}
+
+// Start opens a new session for userID.
+func Start(db *gorm.DB, userID uint) (Tokens, error) {
+	family, err := utils.NewOpaqueToken()
+	if err != nil {
+		return Tokens{}, err
+	}
+	return issue(db, userID, family)
+}
+
+// Refresh exchanges a refresh token for a new pair of tokens in the same
+// session. Each refresh token works once: presenting one that was already
+// used means someone else holds a copy, so the whole session is revoked.
+func Refresh(db *gorm.DB, raw string) (Tokens, error) {
+	var token models.RefreshToken
+	if err := db.Where("token_hash = ?", utils.HashOpaqueToken(raw)).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return Tokens{}, ErrInvalidRefreshToken
+		}
+		return Tokens{}, err
+	}
+	if token.UsedAt != nil {
+		return Tokens{}, reused(db, token.FamilyID)
+	}
+	if token.RevokedAt != nil || time.Now().After(token.ExpiresAt) {
+		return Tokens{}, ErrInvalidRefreshToken
+	}
+
+	var tokens Tokens
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", token.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRefreshTokenReused // Lost a race with another refresh
+		}
+		var err error
+		tokens, err = issue(tx, token.UserID, token.FamilyID)
+		return err
+	})
+	if errors.Is(err, ErrRefreshTokenReused) {
+		return Tokens{}, reused(db, token.FamilyID)
+	}
+	return tokens, err
+}
+
+func reused(db *gorm.DB, familyID string) error {
+	if err := RevokeSession(db, familyID); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+func issue(db *gorm.DB, userID uint, familyID string) (Tokens, error) {
+	access, jti, expires, err := utils.GenerateToken(userID, familyID)
+	if err != nil {
+		return Tokens{}, err
+	}
+	raw, err := utils.NewOpaqueToken()
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	token := models.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: utils.HashOpaqueToken(raw),
+		AccessJTI: jti,
+		ExpiresAt: time.Now().Add(utils.RefreshTokenLifespan()),
+	}
+	if err := db.Create(&token).Error; err != nil {
+		return Tokens{}, err
+	}
+
+	return Tokens{AccessToken: access, RefreshToken: raw, ExpiresIn: int(time.Until(expires).Seconds())}, nil
+}
+
+// RevokeSession ends one session: its refresh tokens stop working and its
+// access tokens are rejected by jti.
+func RevokeSession(db *gorm.DB, familyID string) error {
+	return revoke(db, "family_id", familyID)
+}
+
+// RevokeUser ends every session userID has ("log out everywhere").
+func RevokeUser(db *gorm.DB, userID uint) error {
+	return revoke(db, "user_id", userID)
+}
+
+func revoke(db *gorm.DB, column string, value interface{}) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		lifespan := utils.AccessTokenLifespan()
+
+		// Access tokens were issued with the refresh tokens; only those
+		// young enough to still be valid need to be denied.
+		var live []models.RefreshToken
+		if err := tx.Where(column+" = ?", value).Where("created_at > ? AND access_jti <> ''", now.Add(-lifespan)).Find(&live).Error; err != nil {
+			return err
+		}
+		for _, t := range live {
+			revoked := models.RevokedToken{JTI: t.AccessJTI, ExpiresAt: t.CreatedAt.Add(lifespan)}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&revoked).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&models.RefreshToken{}).Where(column+" = ? AND revoked_at IS NULL", value).Update("revoked_at", now).Error
+	})
+}
+
+// IsRevoked reports whether the access token with this jti was revoked.
+func IsRevoked(db *gorm.DB, jti string) (bool, error) {
+	var count int64
+	err := db.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error
+	return count > 0, err
+}
+
+// Cleanup drops revoked access tokens and refresh tokens that have expired
+// and so can no longer be used anyway.
+func Cleanup(db *gorm.DB, now time.Time) (int64, error) {
+	revoked := db.Where("expires_at < ?", now).Delete(&models.RevokedToken{})
+	if revoked.Error != nil {
+		return 0, revoked.Error
+	}
+	refresh := db.Where("expires_at < ?", now).Delete(&models.RefreshToken{})
+	return revoked.RowsAffected + refresh.RowsAffected, refresh.Error
+}
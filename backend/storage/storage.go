@@ -0,0 +1,72 @@
+// Package storage keeps uploaded file contents outside the database.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrNotFound is returned when no object is stored under a key.
+var ErrNotFound = errors.New("storage: object not found")
+
+// Backend stores opaque blobs under slash-separated keys.
+type Backend interface {
+	// Put stores size bytes read from r under key, replacing any
+	// existing object.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open returns the object's contents; callers must close it.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the object. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// FromEnv builds the backend selected by STORAGE_BACKEND: "local" (the
+// default, rooted at STORAGE_DIR) or "s3" (configured by the S3_*
+// variables).
+func FromEnv() (Backend, error) {
+	switch backend := os.Getenv("STORAGE_BACKEND"); backend {
+	case "", "local":
+		dir := os.Getenv("STORAGE_DIR")
+		if dir == "" {
+			dir = "uploads"
+		}
+		return NewLocal(dir)
+	case "s3":
+		s := &S3{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			Region:          os.Getenv("S3_REGION"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+		}
+		if s.Region == "" {
+			s.Region = "us-east-1"
+		}
+		if s.Endpoint == "" {
+			s.Endpoint = "https://s3." + s.Region + ".amazonaws.com"
+		}
+		if s.Bucket == "" || s.AccessKeyID == "" || s.SecretAccessKey == "" {
+			return nil, errors.New("storage: S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY must be set")
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("storage: unknown STORAGE_BACKEND %q", backend)
+	}
+}
+
+// validKey rejects keys that could escape the backend's namespace.
+func validKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return fmt.Errorf("storage: invalid key %q", key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("storage: invalid key %q", key)
+		}
+	}
+	return nil
+}
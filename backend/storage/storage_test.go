@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 is a minimal in-memory stand-in for an S3 endpoint.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string]string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=test-key/") || !strings.Contains(auth, "/eu-west-1/s3/aws4_request") {
+		http.Error(w, "AccessDenied", http.StatusForbidden)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = string(body)
+	case http.MethodGet:
+		body, ok := f.objects[r.URL.Path]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		io.WriteString(w, body)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func testBackend(t *testing.T, b Backend) {
+	ctx := context.Background()
+
+	require.NoError(t, b.Put(ctx, "tasks/1/a", strings.NewReader("hello"), 5, "text/plain"))
+
+	r, err := b.Open(ctx, "tasks/1/a")
+	require.NoError(t, err)
+	body, _ := io.ReadAll(r)
+	r.Close()
+	assert.Equal(t, "hello", string(body))
+
+	require.NoError(t, b.Delete(ctx, "tasks/1/a"))
+	require.NoError(t, b.Delete(ctx, "tasks/1/a"))
+	_, err = b.Open(ctx, "tasks/1/a")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.Error(t, b.Put(ctx, "../escape", strings.NewReader("x"), 1, ""))
+}
+
+func TestLocal(t *testing.T) {
+	b, err := NewLocal(t.TempDir())
+	require.NoError(t, err)
+	testBackend(t, b)
+}
+
+func TestS3(t *testing.T) {
+	fake := &fakeS3{objects: map[string]string{}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	testBackend(t, &S3{
+		Endpoint:        srv.URL,
+		Bucket:          "attachments",
+		Region:          "eu-west-1",
+		AccessKeyID:     "test-key",
+		SecretAccessKey: "test-secret",
+	})
+}
@@ -0,0 +1,120 @@
+// Package totp implements time-based one-time passwords (RFC 6238) as used
+// by authenticator apps, and the recovery codes that stand in for them.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Digits is the length of a code.
+	Digits = 6
+	// Period is how long each code is current.
+	Period = 30 * time.Second
+	// Skew is how many periods either side of now a code is accepted for,
+	// to allow for clock drift and slow typing.
+	Skew = 1
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a new random 160-bit secret, base32-encoded as
+// authenticator apps expect.
+func GenerateSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(b), nil
+}
+
+// URI returns the otpauth:// URI that enrolls secret in an authenticator
+// app, usually shown as a QR code.
+func URI(issuer, account, secret string) string {
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(Digits))
+	q.Set("period", fmt.Sprint(int(Period/time.Second)))
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// Step returns the time step t falls in.
+func Step(t time.Time) int64 {
+	return t.Unix() / int64(Period/time.Second)
+}
+
+// Code returns the code for secret at time step step.
+func Code(secret string, step int64) (string, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", Digits, value%1000000), nil
+}
+
+// Validate reports whether code is valid for secret at time t, and the
+// time step it matched. Steps up to and including lastStep are refused,
+// so that a code can't be used twice; store the returned step as the
+// next lastStep.
+func Validate(secret, code string, t time.Time, lastStep int64) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != Digits {
+		return 0, false
+	}
+	now := Step(t)
+	for step := now - Skew; step <= now+Skew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		expected, err := Code(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if hmac.Equal([]byte(expected), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// NewRecoveryCode returns a random single-use recovery code, formatted as
+// four groups of four characters for reading aloud or writing down.
+func NewRecoveryCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	s := strings.ToLower(encoding.EncodeToString(b))
+	return s[0:4] + "-" + s[4:8] + "-" + s[8:12] + "-" + s[12:16], nil
+}
+
+// NormalizeRecoveryCode strips the formatting users might add or drop when
+// typing a recovery code, so it can be hashed and compared.
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, code)
+}
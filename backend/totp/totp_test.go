@@ -0,0 +1,67 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The RFC 6238 appendix B vectors for SHA-1, cut to six digits.
+func TestCodeMatchesRFCVectors(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for unix, want := range vectors {
+		got, err := Code(secret, Step(time.Unix(unix, 0)))
+		assert.NoError(t, err)
+		assert.Equal(t, want, got, "t=%d", unix)
+	}
+}
+
+func TestValidateAllowsDriftAndRefusesReuse(t *testing.T) {
+	secret, err := GenerateSecret()
+	assert.NoError(t, err)
+	now := time.Unix(1700000000, 0)
+
+	previous, _ := Code(secret, Step(now)-1)
+	step, ok := Validate(secret, previous, now, 0)
+	assert.True(t, ok)
+	assert.Equal(t, Step(now)-1, step)
+
+	// The same code can't be used again, nor an older one.
+	_, ok = Validate(secret, previous, now, step)
+	assert.False(t, ok)
+	older, _ := Code(secret, Step(now)-2)
+	_, ok = Validate(secret, older, now, 0)
+	assert.False(t, ok)
+
+	next, _ := Code(secret, Step(now)+1)
+	_, ok = Validate(secret, next, now, step)
+	assert.True(t, ok)
+
+	_, ok = Validate(secret, "12345", now, 0)
+	assert.False(t, ok)
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("Task Manager", "ann@example.com", "ABC")
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Task%20Manager:ann@example.com?"))
+	assert.Contains(t, uri, "secret=ABC")
+	assert.Contains(t, uri, "digits=6")
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	code, err := NewRecoveryCode()
+	assert.NoError(t, err)
+	assert.Len(t, code, 19)
+	assert.Equal(t, strings.ReplaceAll(code, "-", ""), NormalizeRecoveryCode(" "+strings.ToUpper(code)))
+}
@@ -0,0 +1,116 @@
+// Package trash permanently removes soft-deleted tasks.
+package trash
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"taskmanager-backend/backend/models"
+	"taskmanager-backend/backend/storage"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultRetention is how long tasks stay in the trash before they are
+// purged, unless TRASH_RETENTION_DAYS says otherwise.
+const DefaultRetention = 30 * 24 * time.Hour
+
+// Retention returns the configured trash retention period.
+func Retention() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS"))
+	if err != nil || days <= 0 {
+		return DefaultRetention
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// Purge hard-deletes tasks that are already in the trash, together with
+// everything attached to them. It returns the storage keys of their
+// attachments, to be passed to RemoveFiles once tx commits.
+func Purge(tx *gorm.DB, taskIDs []uint) ([]string, error) {
+	if len(taskIDs) == 0 {
+		return nil, nil
+	}
+	db := tx.Unscoped().Session(&gorm.Session{})
+
+	// Only tasks still in the trash are purged. Locking them makes a
+	// concurrent restore wait, so it can't slip in before the cascade.
+	var trashed []uint
+	if err := db.Model(&models.Task{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ? AND deleted_at IS NOT NULL", taskIDs).
+		Pluck("id", &trashed).Error; err != nil {
+		return nil, err
+	}
+	if len(trashed) == 0 {
+		return nil, nil
+	}
+	taskIDs = trashed
+
+	var keys []string
+	if err := db.Model(&models.Attachment{}).Where("task_id IN ?", taskIDs).Pluck("storage_key", &keys).Error; err != nil {
+		return nil, err
+	}
+
+	commentIDs := db.Model(&models.Comment{}).Select("id").Where("task_id IN ?", taskIDs)
+	steps := []func() error{
+		func() error { return db.Where("comment_id IN (?)", commentIDs).Delete(&models.CommentRevision{}).Error },
+		func() error { return db.Where("task_id IN ?", taskIDs).Delete(&models.Comment{}).Error },
+		func() error { return db.Where("task_id IN ?", taskIDs).Delete(&models.Attachment{}).Error },
+		func() error {
+			return db.Where("task_id IN ? OR blocker_id IN ?", taskIDs, taskIDs).Delete(&models.TaskDependency{}).Error
+		},
+		func() error { return db.Where("task_id IN ?", taskIDs).Delete(&models.TaskEvent{}).Error },
+		func() error { return db.Exec("DELETE FROM task_tags WHERE task_id IN ?", taskIDs).Error },
+		// Anything still pointing at a purged task loses the link
+		func() error {
+			return db.Model(&models.Task{}).Where("parent_id IN ?", taskIDs).Update("parent_id", nil).Error
+		},
+		func() error {
+			return db.Model(&models.Task{}).Where("next_occurrence_id IN ?", taskIDs).Update("next_occurrence_id", nil).Error
+		},
+		func() error {
+			return db.Where("id IN ? AND deleted_at IS NOT NULL", taskIDs).Delete(&models.Task{}).Error
+		},
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// PurgeExpired permanently deletes every task that has been in the trash
+// since before cutoff, and returns how many it removed.
+func PurgeExpired(db *gorm.DB, files storage.Backend, cutoff time.Time) (int, error) {
+	var ids []uint
+	var keys []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&models.Task{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+			Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		var err error
+		keys, err = Purge(tx, ids)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	RemoveFiles(files, keys)
+	return len(ids), nil
+}
+
+// RemoveFiles deletes blobs whose rows are already gone. Failures only
+// leak storage, so they are logged rather than returned.
+func RemoveFiles(files storage.Backend, keys []string) {
+	for _, key := range keys {
+		if err := files.Delete(context.Background(), key); err != nil {
+			log.Printf("Failed to delete stored file %s: %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// NewOpaqueToken returns a random URL-safe token for links and API keys
+// that are shown to the user once and only stored hashed.
+func NewOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// HashOpaqueToken returns the value to store for token. The tokens carry
+// 256 bits of entropy, so a fast hash is enough.
+func HashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
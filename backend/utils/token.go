@@ -9,20 +9,45 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func GenerateToken(user_id uint) (string, error) {
-	token_lifespan, err := strconv.Atoi(os.Getenv("TOKEN_HOUR_LIFESPAN"))
+// AccessTokenLifespan is how long an access token is valid. Access tokens
+// are short-lived; clients renew them with a refresh token.
+func AccessTokenLifespan() time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv("ACCESS_TOKEN_MINUTES"))
+	if err != nil || minutes <= 0 {
+		minutes = 15
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// RefreshTokenLifespan is how long a refresh token can be used.
+func RefreshTokenLifespan() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("REFRESH_TOKEN_DAYS"))
+	if err != nil || days <= 0 {
+		days = 30
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// GenerateToken issues an access token for user_id within the session
+// session_id. The token's jti is returned so it can be revoked later.
+func GenerateToken(user_id uint, session_id string) (token string, jti string, expires time.Time, err error) {
+	jti, err = NewOpaqueToken()
 	if err != nil {
-		token_lifespan = 24 // default to 24 hours
+		return "", "", time.Time{}, err
 	}
+	now := time.Now()
+	expires = now.Add(AccessTokenLifespan())
 
 	claims := jwt.MapClaims{}
 	claims["authorized"] = true
 	claims["user_id"] = user_id
-	claims["exp"] = time.Now().Add(time.Hour * time.Duration(token_lifespan)).Unix()
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	claims["sid"] = session_id
+	claims["jti"] = jti
+	claims["iat"] = now.Unix()
+	claims["exp"] = expires.Unix()
 
-	return token.SignedString([]byte(os.Getenv("API_SECRET")))
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(os.Getenv("API_SECRET")))
+	return signed, jti, expires, err
 }
 
 func ValidateToken(tokenString string) (*jwt.Token, error) {
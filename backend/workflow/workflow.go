@@ -0,0 +1,170 @@
+// Package workflow enforces which task status changes are allowed and runs
+// side effects attached to them.
+package workflow
+
+import (
+	"fmt"
+	"sort"
+	"taskmanager-backend/backend/models"
+	"time"
+)
+
+// Hook runs after a task has moved from one status to another.
+type Hook func(task *models.Task, from, to string, now time.Time)
+
+// Machine is a set of statuses, the moves allowed between them and hooks
+// that fire on entering or leaving a status.
+type Machine struct {
+	states  map[string]bool
+	allowed map[string]map[string]bool
+	onEnter map[string][]Hook
+	onLeave map[string][]Hook
+}
+
+// TransitionError reports a status change the machine does not allow.
+type TransitionError struct {
+	From    string
+	To      string
+	Allowed []string
+}
+
+func (e *TransitionError) Error() string {
+	if e.From == "" {
+		return fmt.Sprintf("invalid status %q", e.To)
+	}
+	return fmt.Sprintf("cannot move task from %q to %q", e.From, e.To)
+}
+
+func NewMachine(states ...string) *Machine {
+	m := &Machine{
+		states:  map[string]bool{},
+		allowed: map[string]map[string]bool{},
+		onEnter: map[string][]Hook{},
+		onLeave: map[string][]Hook{},
+	}
+	for _, s := range states {
+		m.states[s] = true
+		m.allowed[s] = map[string]bool{}
+	}
+	return m
+}
+
+// Allow permits moving from `from` to each of `to`.
+func (m *Machine) Allow(from string, to ...string) *Machine {
+	for _, t := range to {
+		m.allowed[from][t] = true
+	}
+	return m
+}
+
+func (m *Machine) OnEnter(state string, hook Hook) *Machine {
+	m.onEnter[state] = append(m.onEnter[state], hook)
+	return m
+}
+
+func (m *Machine) OnLeave(state string, hook Hook) *Machine {
+	m.onLeave[state] = append(m.onLeave[state], hook)
+	return m
+}
+
+func (m *Machine) Valid(state string) bool {
+	return m.states[state]
+}
+
+// Targets lists the statuses reachable from `from` in one move.
+func (m *Machine) Targets(from string) []string {
+	var out []string
+	for to := range m.allowed[from] {
+		out = append(out, to)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Start puts a new task into its initial status, running enter hooks.
+func (m *Machine) Start(task *models.Task, status string, now time.Time) error {
+	if !m.Valid(status) {
+		return &TransitionError{To: status}
+	}
+	task.Status = status
+	for _, hook := range m.onEnter[status] {
+		hook(task, "", status, now)
+	}
+	return nil
+}
+
+// Transition moves task to status if the move is allowed. Staying in the
+// same status is always allowed and runs no hooks.
+func (m *Machine) Transition(task *models.Task, status string, now time.Time) error {
+	from := task.Status
+	if !m.Valid(status) {
+		return &TransitionError{To: status}
+	}
+	if from == status {
+		return nil
+	}
+	if !m.allowed[from][status] {
+		return &TransitionError{From: from, To: status, Allowed: m.Targets(from)}
+	}
+	m.move(task, status, now)
+	return nil
+}
+
+// Restore puts task back into a status it held before, such as when a
+// revert rewinds its history. Any valid status is allowed, since the task
+// was there once, but the hooks still run as for a transition.
+func (m *Machine) Restore(task *models.Task, status string, now time.Time) error {
+	if !m.Valid(status) {
+		return &TransitionError{To: status}
+	}
+	if task.Status == status {
+		return nil
+	}
+	m.move(task, status, now)
+	return nil
+}
+
+// move sets the task's status and runs the hooks for leaving the old one
+// and entering the new one.
+func (m *Machine) move(task *models.Task, status string, now time.Time) {
+	from := task.Status
+	task.Status = status
+	for _, hook := range m.onLeave[from] {
+		hook(task, from, status, now)
+	}
+	for _, hook := range m.onEnter[status] {
+		hook(task, from, status, now)
+	}
+}
+
+func stampCompletedAt(task *models.Task, from, to string, now time.Time) {
+	task.CompletedAt = &now
+}
+
+func clearCompletedAt(task *models.Task, from, to string, now time.Time) {
+	task.CompletedAt = nil
+}
+
+// Tasks is the status workflow applied to every task:
+//
+//	pending <-> in-progress -> completed
+//	pending -> completed, completed -> pending (reopen)
+//
+// A completed task can't go straight back to in-progress; it is reopened
+// first, so that reopening is always a deliberate step.
+var Tasks = NewMachine(models.TaskStatuses...).
+	Allow(models.StatusPending, models.StatusInProgress, models.StatusCompleted).
+	Allow(models.StatusInProgress, models.StatusPending, models.StatusCompleted).
+	Allow(models.StatusCompleted, models.StatusPending).
+	OnEnter(models.StatusCompleted, stampCompletedAt).
+	OnLeave(models.StatusCompleted, clearCompletedAt)
+
+// ValidPriority reports whether p is one of models.TaskPriorities.
+func ValidPriority(p string) bool {
+	for _, allowed := range models.TaskPriorities {
+		if p == allowed {
+			return true
+		}
+	}
+	return false
+}
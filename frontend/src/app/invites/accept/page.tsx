@@ -0,0 +1,45 @@
+'use client';
+
+import React, { useEffect, useState } from 'react';
+import { acceptWorkspaceInvite } from '@/lib/api';
+import Link from 'next/link';
+
+export default function AcceptInvitePage() {
+  const [status, setStatus] = useState<'accepting' | 'accepted' | 'failed'>('accepting');
+  const [error, setError] = useState('');
+
+  useEffect(() => {
+    const token = new URLSearchParams(window.location.search).get('token');
+    if (!token) {
+      setStatus('failed');
+      setError('This invite link is incomplete.');
+      return;
+    }
+    if (!localStorage.getItem('token')) {
+      setStatus('failed');
+      setError('Log in with the invited email address, then open the link again.');
+      return;
+    }
+    acceptWorkspaceInvite(token)
+      .then(() => setStatus('accepted'))
+      // eslint-disable-next-line @typescript-eslint/no-explicit-any
+      .catch((err: any) => {
+        setStatus('failed');
+        setError(err.response?.data?.error || 'Could not accept the invite');
+      });
+  }, []);
+
+  return (
+    <div className="min-h-screen flex items-center justify-center bg-gray-50">
+      <div className="max-w-md w-full space-y-6 p-8 bg-white rounded-xl shadow-lg text-center">
+        <h2 className="text-3xl font-extrabold text-gray-900">Workspace invite</h2>
+        {status === 'accepting' && <p className="text-gray-600">Joining the workspace…</p>}
+        {status === 'accepted' && <p className="text-green-600">You joined the workspace.</p>}
+        {status === 'failed' && <p className="text-red-500 text-sm">{error}</p>}
+        <Link href={status === 'failed' ? '/login' : '/'} className="font-medium text-blue-600 hover:text-blue-500">
+          {status === 'failed' ? 'Go to login' : 'Go to your tasks'}
+        </Link>
+      </div>
+    </div>
+  );
+}
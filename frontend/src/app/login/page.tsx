@@ -1,18 +1,25 @@
 'use client';
 
 import React, { useState } from 'react';
-import { login } from '@/lib/api';
+import { login, loginTwoFactor } from '@/lib/api';
 import Link from 'next/link';
 
 export default function LoginPage() {
   const [email, setEmail] = useState('');
   const [password, setPassword] = useState('');
+  const [mfaToken, setMfaToken] = useState<string | null>(null);
+  const [code, setCode] = useState('');
   const [error, setError] = useState('');
 
   const handleSubmit = async (e: React.FormEvent) => {
     e.preventDefault();
+    setError('');
     try {
-      await login(email, password);
+      const data = mfaToken ? await loginTwoFactor(mfaToken, code) : await login(email, password);
+      if (data.mfa_required && data.mfa_token) {
+        setMfaToken(data.mfa_token);
+        return;
+      }
       window.location.href = '/';
     // eslint-disable-next-line @typescript-eslint/no-explicit-any
     } catch (err: any) {
@@ -32,6 +39,24 @@ export default function LoginPage() {
           {error && (
             <div className="text-red-500 text-sm text-center">{error}</div>
           )}
+          {mfaToken ? (
+            <div>
+              <p className="text-sm text-gray-600 mb-2">
+                Enter the code from your authenticator app, or one of your recovery codes.
+              </p>
+              <input
+                type="text"
+                inputMode="numeric"
+                autoComplete="one-time-code"
+                required
+                autoFocus
+                className="appearance-none rounded-md relative block w-full px-3 py-2 border border-gray-300 placeholder-gray-500 text-gray-900 focus:outline-none focus:ring-blue-500 focus:border-blue-500 sm:text-sm"
+                placeholder="Authentication code"
+                value={code}
+                onChange={(e) => setCode(e.target.value)}
+              />
+            </div>
+          ) : (
           <div className="rounded-md shadow-sm -space-y-px">
             <div>
               <input
@@ -54,20 +79,28 @@ export default function LoginPage() {
               />
             </div>
           </div>
+          )}
 
           <div>
             <button
               type="submit"
               className="group relative w-full flex justify-center py-2 px-4 border border-transparent text-sm font-medium rounded-md text-white bg-blue-600 hover:bg-blue-700 focus:outline-none focus:ring-2 focus:ring-offset-2 focus:ring-blue-500"
             >
-              Sign in
+              {mfaToken ? 'Verify' : 'Sign in'}
             </button>
           </div>
           
-          <div className="text-sm text-center">
-            <Link href="/signup" className="font-medium text-blue-600 hover:text-blue-500">
-              Don&apos;t have an account? Sign up
-            </Link>
+          <div className="text-sm text-center space-y-2">
+            <div>
+              <Link href="/signup" className="font-medium text-blue-600 hover:text-blue-500">
+                Don&apos;t have an account? Sign up
+              </Link>
+            </div>
+            <div>
+              <Link href="/forgot-password" className="font-medium text-blue-600 hover:text-blue-500">
+                Forgot your password?
+              </Link>
+            </div>
           </div>
         </form>
       </div>
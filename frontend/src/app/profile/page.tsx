@@ -3,6 +3,8 @@
 import { useEffect, useState } from 'react';
 import { useRouter } from 'next/navigation';
 import { getCurrentUser, updateProfile } from '@/lib/api';
+import TwoFactorSettings from '@/components/TwoFactorSettings';
+import AccessTokenSettings from '@/components/AccessTokenSettings';
 
 interface UserProfile {
   id: number;
@@ -10,6 +12,7 @@ interface UserProfile {
   email: string;
   role: string;
   credits: number;
+  two_factor_enabled: boolean;
   created_at: string;
 }
 
@@ -188,6 +191,13 @@ export default function ProfilePage() {
             )}
           </div>
         </div>
+
+        <TwoFactorSettings
+          enabled={user.two_factor_enabled}
+          onChange={(enabled) => setUser({ ...user, two_factor_enabled: enabled })}
+        />
+
+        <AccessTokenSettings />
       </div>
     </div>
   );
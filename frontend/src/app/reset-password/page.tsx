@@ -0,0 +1,63 @@
+'use client';
+
+import React, { useState } from 'react';
+import { resetPassword } from '@/lib/api';
+import Link from 'next/link';
+
+export default function ResetPasswordPage() {
+  const [password, setPassword] = useState('');
+  const [done, setDone] = useState(false);
+  const [error, setError] = useState('');
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setError('');
+    const token = new URLSearchParams(window.location.search).get('token') || '';
+    try {
+      await resetPassword(token, password);
+      setDone(true);
+    // eslint-disable-next-line @typescript-eslint/no-explicit-any
+    } catch (err: any) {
+      setError(err.response?.data?.error || 'Reset failed');
+    }
+  };
+
+  return (
+    <div className="min-h-screen flex items-center justify-center bg-gray-50">
+      <div className="max-w-md w-full space-y-8 p-8 bg-white rounded-xl shadow-lg">
+        <h2 className="mt-6 text-center text-3xl font-extrabold text-gray-900">
+          Choose a new password
+        </h2>
+        {done ? (
+          <p className="text-green-600 text-sm text-center">
+            Your password has been reset and you have been signed out everywhere.
+          </p>
+        ) : (
+          <form className="space-y-6" onSubmit={handleSubmit}>
+            {error && <div className="text-red-500 text-sm text-center">{error}</div>}
+            <input
+              type="password"
+              required
+              minLength={8}
+              className="appearance-none rounded-md relative block w-full px-3 py-2 border border-gray-300 placeholder-gray-500 text-gray-900 focus:outline-none focus:ring-blue-500 focus:border-blue-500 sm:text-sm"
+              placeholder="New password (at least 8 characters)"
+              value={password}
+              onChange={(e) => setPassword(e.target.value)}
+            />
+            <button
+              type="submit"
+              className="w-full flex justify-center py-2 px-4 border border-transparent text-sm font-medium rounded-md text-white bg-blue-600 hover:bg-blue-700 focus:outline-none focus:ring-2 focus:ring-offset-2 focus:ring-blue-500"
+            >
+              Reset password
+            </button>
+          </form>
+        )}
+        <div className="text-sm text-center">
+          <Link href="/login" className="font-medium text-blue-600 hover:text-blue-500">
+            Back to sign in
+          </Link>
+        </div>
+      </div>
+    </div>
+  );
+}
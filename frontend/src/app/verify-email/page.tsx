@@ -0,0 +1,40 @@
+'use client';
+
+import React, { useEffect, useState } from 'react';
+import { verifyEmail } from '@/lib/api';
+import Link from 'next/link';
+
+export default function VerifyEmailPage() {
+  const [status, setStatus] = useState<'verifying' | 'verified' | 'failed'>('verifying');
+  const [error, setError] = useState('');
+
+  useEffect(() => {
+    const token = new URLSearchParams(window.location.search).get('token');
+    if (!token) {
+      setStatus('failed');
+      setError('This verification link is incomplete.');
+      return;
+    }
+    verifyEmail(token)
+      .then(() => setStatus('verified'))
+      // eslint-disable-next-line @typescript-eslint/no-explicit-any
+      .catch((err: any) => {
+        setStatus('failed');
+        setError(err.response?.data?.error || 'Verification failed');
+      });
+  }, []);
+
+  return (
+    <div className="min-h-screen flex items-center justify-center bg-gray-50">
+      <div className="max-w-md w-full space-y-6 p-8 bg-white rounded-xl shadow-lg text-center">
+        <h2 className="text-3xl font-extrabold text-gray-900">Email verification</h2>
+        {status === 'verifying' && <p className="text-gray-600">Verifying your email address…</p>}
+        {status === 'verified' && <p className="text-green-600">Your email address is verified.</p>}
+        {status === 'failed' && <p className="text-red-500 text-sm">{error}</p>}
+        <Link href="/" className="font-medium text-blue-600 hover:text-blue-500">
+          Go to your tasks
+        </Link>
+      </div>
+    </div>
+  );
+}
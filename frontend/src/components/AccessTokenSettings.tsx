@@ -0,0 +1,123 @@
+import React, { useEffect, useState } from 'react';
+import { AccessToken, getAccessTokens, createAccessToken, deleteAccessToken } from '@/lib/api';
+
+const SCOPES = [
+  { value: 'read:tasks', label: 'Read tasks' },
+  { value: 'write:tasks', label: 'Write tasks' },
+  { value: 'billing', label: 'Billing' },
+];
+
+const inputClass =
+  'mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm p-2 border text-gray-900';
+
+export default function AccessTokenSettings() {
+  const [tokens, setTokens] = useState<AccessToken[]>([]);
+  const [name, setName] = useState('');
+  const [scopes, setScopes] = useState<string[]>(['read:tasks']);
+  const [expiresInDays, setExpiresInDays] = useState('90');
+  const [newToken, setNewToken] = useState<string | null>(null);
+  const [error, setError] = useState('');
+
+  useEffect(() => {
+    getAccessTokens().then(setTokens).catch(() => setError('Could not load API tokens'));
+  }, []);
+
+  const toggleScope = (scope: string) => {
+    setScopes(scopes.includes(scope) ? scopes.filter((s) => s !== scope) : [...scopes, scope]);
+  };
+
+  const handleCreate = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setError('');
+    try {
+      const created = await createAccessToken(name, scopes, expiresInDays ? Number(expiresInDays) : undefined);
+      setNewToken(created.token);
+      setTokens([created.data, ...tokens]);
+      setName('');
+    // eslint-disable-next-line @typescript-eslint/no-explicit-any
+    } catch (err: any) {
+      setError(err.response?.data?.error || 'Could not create API token');
+    }
+  };
+
+  const handleDelete = async (id: number) => {
+    setError('');
+    try {
+      await deleteAccessToken(id);
+      setTokens(tokens.filter((t) => t.id !== id));
+    // eslint-disable-next-line @typescript-eslint/no-explicit-any
+    } catch (err: any) {
+      setError(err.response?.data?.error || 'Could not revoke API token');
+    }
+  };
+
+  return (
+    <div className="bg-white border rounded-lg p-6 mt-6">
+      <h2 className="text-xl font-semibold text-gray-900 mb-2">API Tokens</h2>
+      <p className="text-sm text-gray-600 mb-4">
+        Tokens let scripts and CI use the API as you, limited to the scopes you choose. Send one as{' '}
+        <code className="text-gray-900">Authorization: Bearer &lt;token&gt;</code>.
+      </p>
+
+      {error && <div className="mb-4 p-3 rounded bg-red-100 text-red-700 text-sm">{error}</div>}
+
+      {newToken && (
+        <div className="mb-4 p-4 rounded bg-yellow-50 border border-yellow-200">
+          <p className="text-sm text-yellow-800 mb-2">Copy your new token now; it won&apos;t be shown again.</p>
+          <p className="font-mono text-sm break-all text-gray-900">{newToken}</p>
+        </div>
+      )}
+
+      <form onSubmit={handleCreate} className="space-y-4 mb-6">
+        <div>
+          <label className="block text-sm font-medium text-gray-700">Name</label>
+          <input type="text" value={name} onChange={(e) => setName(e.target.value)} className={inputClass} placeholder="e.g. Nightly CI" required />
+        </div>
+        <div className="flex gap-4">
+          {SCOPES.map((scope) => (
+            <label key={scope.value} className="flex items-center gap-2 text-sm text-gray-700">
+              <input type="checkbox" checked={scopes.includes(scope.value)} onChange={() => toggleScope(scope.value)} />
+              {scope.label}
+            </label>
+          ))}
+        </div>
+        <div>
+          <label className="block text-sm font-medium text-gray-700">Expires</label>
+          <select value={expiresInDays} onChange={(e) => setExpiresInDays(e.target.value)} className={inputClass}>
+            <option value="30">In 30 days</option>
+            <option value="90">In 90 days</option>
+            <option value="365">In a year</option>
+            <option value="">Never</option>
+          </select>
+        </div>
+        <button
+          type="submit"
+          disabled={scopes.length === 0}
+          className="py-2 px-4 border border-transparent rounded-md shadow-sm text-sm font-medium text-white bg-blue-600 hover:bg-blue-700 disabled:opacity-50"
+        >
+          Create Token
+        </button>
+      </form>
+
+      <ul className="divide-y">
+        {tokens.map((token) => (
+          <li key={token.id} className="py-3 flex justify-between items-center">
+            <div>
+              <p className="font-medium text-gray-900">
+                {token.name} <span className="font-mono text-xs text-gray-500">…{token.hint}</span>
+              </p>
+              <p className="text-xs text-gray-500">
+                {token.scopes.join(', ')} ·{' '}
+                {token.last_used_at ? `last used ${new Date(token.last_used_at).toLocaleString()}` : 'never used'} ·{' '}
+                {token.expires_at ? `expires ${new Date(token.expires_at).toLocaleDateString()}` : 'no expiry'}
+              </p>
+            </div>
+            <button onClick={() => handleDelete(token.id)} className="text-sm text-red-600 hover:text-red-800">
+              Revoke
+            </button>
+          </li>
+        ))}
+      </ul>
+    </div>
+  );
+}
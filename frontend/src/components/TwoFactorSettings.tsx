@@ -0,0 +1,146 @@
+import React, { useState } from 'react';
+import { enrollTwoFactor, confirmTwoFactor, disableTwoFactor, regenerateRecoveryCodes } from '@/lib/api';
+
+interface TwoFactorSettingsProps {
+  enabled: boolean;
+  onChange: (enabled: boolean) => void;
+}
+
+const inputClass =
+  'mt-1 block w-full rounded-md border-gray-300 shadow-sm focus:border-blue-500 focus:ring-blue-500 sm:text-sm p-2 border text-gray-900';
+const buttonClass =
+  'py-2 px-4 border border-transparent rounded-md shadow-sm text-sm font-medium text-white bg-blue-600 hover:bg-blue-700';
+
+export default function TwoFactorSettings({ enabled, onChange }: TwoFactorSettingsProps) {
+  const [password, setPassword] = useState('');
+  const [code, setCode] = useState('');
+  const [enrollment, setEnrollment] = useState<{ secret: string; otpauth_url: string } | null>(null);
+  const [recoveryCodes, setRecoveryCodes] = useState<string[] | null>(null);
+  const [error, setError] = useState('');
+
+  const run = async (action: () => Promise<void>, fallback: string) => {
+    setError('');
+    try {
+      await action();
+    // eslint-disable-next-line @typescript-eslint/no-explicit-any
+    } catch (err: any) {
+      setError(err.response?.data?.error || fallback);
+    }
+  };
+
+  const handleEnroll = (e: React.FormEvent) => {
+    e.preventDefault();
+    run(async () => {
+      setEnrollment(await enrollTwoFactor(password));
+      setPassword('');
+    }, 'Could not start enrollment');
+  };
+
+  const handleConfirm = (e: React.FormEvent) => {
+    e.preventDefault();
+    run(async () => {
+      setRecoveryCodes(await confirmTwoFactor(code));
+      setEnrollment(null);
+      setCode('');
+      onChange(true);
+    }, 'Could not enable two-factor authentication');
+  };
+
+  const handleDisable = (e: React.FormEvent) => {
+    e.preventDefault();
+    run(async () => {
+      await disableTwoFactor(password, code);
+      setPassword('');
+      setCode('');
+      setRecoveryCodes(null);
+      onChange(false);
+    }, 'Could not disable two-factor authentication');
+  };
+
+  const handleRegenerate = () => {
+    run(async () => {
+      setRecoveryCodes(await regenerateRecoveryCodes(code));
+      setCode('');
+    }, 'Could not create recovery codes');
+  };
+
+  return (
+    <div className="bg-white border rounded-lg p-6 mt-6">
+      <h2 className="text-xl font-semibold text-gray-900 mb-2">Two-Factor Authentication</h2>
+      <p className="text-sm text-gray-600 mb-4">
+        {enabled
+          ? 'Two-factor authentication is on. Signing in asks for a code from your authenticator app.'
+          : 'Protect your account with a code from an authenticator app when you sign in.'}
+      </p>
+
+      {error && <div className="mb-4 p-3 rounded bg-red-100 text-red-700 text-sm">{error}</div>}
+
+      {recoveryCodes && (
+        <div className="mb-4 p-4 rounded bg-yellow-50 border border-yellow-200">
+          <p className="text-sm text-yellow-800 mb-2">
+            Save these recovery codes somewhere safe. Each one signs you in once if you lose your authenticator; they
+            won&apos;t be shown again.
+          </p>
+          <ul className="grid grid-cols-2 gap-1 font-mono text-sm text-gray-900">
+            {recoveryCodes.map((c) => <li key={c}>{c}</li>)}
+          </ul>
+        </div>
+      )}
+
+      {!enabled && !enrollment && (
+        <form onSubmit={handleEnroll} className="space-y-4">
+          <div>
+            <label className="block text-sm font-medium text-gray-700">Current Password</label>
+            <input type="password" value={password} onChange={(e) => setPassword(e.target.value)} className={inputClass} required />
+          </div>
+          <button type="submit" className={buttonClass}>Set Up Two-Factor Authentication</button>
+        </form>
+      )}
+
+      {!enabled && enrollment && (
+        <form onSubmit={handleConfirm} className="space-y-4">
+          <p className="text-sm text-gray-700">
+            Add this account to your authenticator app by opening the{' '}
+            <a href={enrollment.otpauth_url} className="text-blue-600 hover:text-blue-800">setup link</a>{' '}
+            or entering the key below, then type the code it shows.
+          </p>
+          <p className="font-mono text-sm break-all bg-gray-50 p-2 rounded text-gray-900">{enrollment.secret}</p>
+          <div>
+            <label className="block text-sm font-medium text-gray-700">Authentication Code</label>
+            <input
+              type="text"
+              inputMode="numeric"
+              autoComplete="one-time-code"
+              value={code}
+              onChange={(e) => setCode(e.target.value)}
+              className={inputClass}
+              required
+            />
+          </div>
+          <button type="submit" className={buttonClass}>Turn On</button>
+        </form>
+      )}
+
+      {enabled && (
+        <form onSubmit={handleDisable} className="space-y-4">
+          <div>
+            <label className="block text-sm font-medium text-gray-700">Authentication or Recovery Code</label>
+            <input type="text" value={code} onChange={(e) => setCode(e.target.value)} className={inputClass} required />
+          </div>
+          <div>
+            <label className="block text-sm font-medium text-gray-700">Current Password (to turn off)</label>
+            <input type="password" value={password} onChange={(e) => setPassword(e.target.value)} className={inputClass} />
+          </div>
+          <div className="flex gap-3">
+            <button type="button" onClick={handleRegenerate} disabled={!code} className={`${buttonClass} disabled:opacity-50`}>
+              New Recovery Codes
+            </button>
+            <button type="submit" disabled={!password} className="py-2 px-4 rounded-md text-sm font-medium text-white bg-red-600 hover:bg-red-700 disabled:opacity-50">
+              Turn Off
+            </button>
+          </div>
+        </form>
+      )}
+    </div>
+  );
+}
@@ -12,6 +12,44 @@ api.interceptors.request.use((config) => {
     return config;
 });
 
+// Access tokens are short-lived: on a 401, trade the refresh token for a
+// new pair once and retry. Concurrent 401s share the same refresh.
+let refreshing: Promise<string | null> | null = null;
+
+const refreshSession = async () => {
+    const refreshToken = localStorage.getItem('refresh_token');
+    if (!refreshToken) {
+        return null;
+    }
+    try {
+        const response = await axios.post<{ token: string; refresh_token: string }>(
+            `${api.defaults.baseURL}/auth/refresh`,
+            { refresh_token: refreshToken },
+        );
+        localStorage.setItem('token', response.data.token);
+        localStorage.setItem('refresh_token', response.data.refresh_token);
+        return response.data.token;
+    } catch {
+        return null;
+    }
+};
+
+api.interceptors.response.use(undefined, async (error) => {
+    const original = error.config;
+    if (typeof window === 'undefined' || error.response?.status !== 401 || !original || original._retried) {
+        return Promise.reject(error);
+    }
+    original._retried = true;
+
+    refreshing = refreshing || refreshSession().finally(() => { refreshing = null; });
+    const token = await refreshing;
+    if (!token) {
+        return Promise.reject(error);
+    }
+    original.headers.Authorization = `Bearer ${token}`;
+    return api(original);
+});
+
 export interface User {
     id: number;
     name: string;
@@ -19,30 +57,133 @@ export interface User {
     role: string;
 }
 
+// When the account has two-factor authentication, login answers with
+// mfa_required and an mfa_token instead of a session; finish with
+// loginTwoFactor.
 export interface LoginResponse {
     token: string;
+    refresh_token: string;
+    expires_in: number;
     user: User;
+    mfa_required?: boolean;
+    mfa_token?: string;
 }
 
 export const register = async (name: string, email: string, password: string) => {
     return await api.post('/auth/signup', { name, email, password });
 };
 
+const storeSession = (data: LoginResponse) => {
+    if (data.token) {
+        localStorage.setItem('token', data.token);
+        localStorage.setItem('refresh_token', data.refresh_token);
+        localStorage.setItem('user', JSON.stringify(data.user));
+    }
+    return data;
+};
+
 export const login = async (email: string, password: string) => {
     const response = await api.post<LoginResponse>('/auth/login', { email, password });
-    if (response.data.token) {
-        localStorage.setItem('token', response.data.token);
-        localStorage.setItem('user', JSON.stringify(response.data.user));
-    }
+    return storeSession(response.data);
+};
+
+export const loginTwoFactor = async (mfaToken: string, code: string) => {
+    const response = await api.post<LoginResponse>('/auth/login/2fa', { mfa_token: mfaToken, code });
+    return storeSession(response.data);
+};
+
+export const enrollTwoFactor = async (password: string) => {
+    const response = await api.post<{ secret: string; otpauth_url: string }>('/auth/2fa/enroll', { password });
     return response.data;
 };
 
-export const logout = () => {
+export const confirmTwoFactor = async (code: string) => {
+    const response = await api.post<{ recovery_codes: string[] }>('/auth/2fa/confirm', { code });
+    return response.data.recovery_codes;
+};
+
+export const disableTwoFactor = async (password: string, code: string) => {
+    return await api.post('/auth/2fa/disable', { password, code });
+};
+
+export const regenerateRecoveryCodes = async (code: string) => {
+    const response = await api.post<{ recovery_codes: string[] }>('/auth/2fa/recovery-codes', { code });
+    return response.data.recovery_codes;
+};
+
+const clearSession = () => {
     localStorage.removeItem('token');
+    localStorage.removeItem('refresh_token');
     localStorage.removeItem('user');
     window.location.href = '/login';
 };
 
+export const logout = async () => {
+    try {
+        await api.post('/auth/logout');
+    } finally {
+        clearSession();
+    }
+};
+
+export const logoutEverywhere = async () => {
+    try {
+        await api.post('/auth/logout-all');
+    } finally {
+        clearSession();
+    }
+};
+
+export const verifyEmail = async (token: string) => {
+    return await api.post('/auth/verify-email', { token });
+};
+
+export const resendVerification = async () => {
+    return await api.post('/auth/verify-email/resend');
+};
+
+export const forgotPassword = async (email: string) => {
+    return await api.post('/auth/forgot-password', { email });
+};
+
+export const resetPassword = async (token: string, password: string) => {
+    return await api.post('/auth/reset-password', { token, password });
+};
+
+export const acceptWorkspaceInvite = async (token: string) => {
+    return await api.post('/invites/accept', { token });
+};
+
+export interface AccessToken {
+    id: number;
+    name: string;
+    hint: string;
+    scopes: string[];
+    expires_at: string | null;
+    last_used_at: string | null;
+    last_used_ip: string;
+    created_at: string;
+}
+
+export const getAccessTokens = async () => {
+    const response = await api.get<{ data: AccessToken[] }>('/auth/tokens');
+    return response.data.data;
+};
+
+// The token itself is only returned here; it can't be fetched again.
+export const createAccessToken = async (name: string, scopes: string[], expiresInDays?: number) => {
+    const response = await api.post<{ token: string; data: AccessToken }>('/auth/tokens', {
+        name,
+        scopes,
+        expires_in_days: expiresInDays,
+    });
+    return response.data;
+};
+
+export const deleteAccessToken = async (id: number) => {
+    return await api.delete(`/auth/tokens/${id}`);
+};
+
 export const getCurrentUser = async () => {
     const response = await api.get<{data: User}>('/auth/me');
     return response.data.data;
@@ -74,9 +215,14 @@ export interface CreateTaskInput {
   assignee: string;
 }
 
+export interface TaskPage {
+  data: Task[];
+  next_cursor: string | null;
+}
+
 export const getTasks = async () => {
-  const response = await api.get<Task[]>('/tasks');
-  return response.data;
+  const response = await api.get<TaskPage>('/tasks', { params: { limit: 200 } });
+  return response.data.data;
 };
 
 export const createTask = async (task: CreateTaskInput) => {